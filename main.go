@@ -3,17 +3,22 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"log"
 	"merge-logs/mergedlog"
 	"os"
 	"path/filepath"
 	"regexp"
 	"runtime/pprof"
+	"strings"
 	"time"
 
 	flag "github.com/spf13/pflag"
 )
 
+// followInterval is how often followed logs are polled for more data
+const followInterval = 250 * time.Millisecond
+
 var userColor string
 var palette []mergedlog.ColorFn
 
@@ -32,74 +37,159 @@ func init() {
 
 func main() {
 	flag.StringVar(&userColor, "color", "dark", "ColorFn scheme to use: light, dark or none")
-	duration := flag.Int64("duration", mergedlog.MAX_INT, "duration (in seconds), relative to start or stop, to display")
+	duration := flag.String("duration", "", "duration, relative to start or stop, to display. Whole seconds or a Go duration such as '90s' or '1h30m'")
 	maxBuffer := flag.Int("max-buffer", 1024*1024, "maximum size of buffer to use when scanning")
-	rangeStartStr := flag.String("start", "", "start timestamp of range of logs. Format: '2018/01/25 19:09:36.949 UTC'")
-	rangeStopStr := flag.String("stop", "", "end timestamp of range of logs. Format: '2018/01/25 19:09:36.949 UTC'")
+	rangeStartStr := flag.String("start", "", "start of range of logs. For example '2018/01/25 19:09:36.949 UTC', '2018-01-25T19:09:36Z', '19:09:36' or 'first+10m'")
+	rangeStopStr := flag.String("stop", "", "end of range of logs. For example '2018/01/25 19:10:00 UTC', '19:10', 'last' or 'last-5m'")
+	around := flag.String("around", "", "limit the logs to a window around a time, given in any of the forms accepted by --start")
+	aroundMatch := flag.String("around-match", "", "limit the logs to a window around the first entry matching the regex")
+	window := flag.Duration("window", 30*time.Second, "with --around or --around-match, how far the window extends either side of the anchor")
 	debugLevel := flag.Int("debug", 0, "debug level - 0=off 1=verbose 2=very verbose")
 	fullAlias := flag.Bool("full-alias", true, "use the full name as alias")
 	noLogRoll := flag.Bool("no-roll", false, "do not attempt to use the log rolling suffix numbers to associate different files with the same system (color)")
-	grep := flag.StringP("grep", "g", "", "only process and display lines containing the regex")
+	greps := flag.StringArrayP("grep", "g", nil, "only process and display entries containing the regex. May be repeated to display entries containing any of them")
+	grepAll := flag.Bool("grep-all", false, "with several --grep options, only display entries containing all of the regexes")
+	excludes := flag.StringArrayP("exclude", "v", nil, "do not display entries containing the regex. May be repeated")
+	level := flag.String("level", "", "only display entries of the given levels, for example 'warning+' (warning or more severe) or 'config,error+'")
+	thread := flag.String("thread", "", "only display entries logged by threads whose name matches the regex")
+	tids := flag.StringSlice("tid", nil, "only display entries logged by the thread with the given ID, for example '0x4f'. May be repeated")
+	where := flag.String("where", "", "only display entries selected by the expression, for example 'level >= warning and not msg ~ heartbeat'")
+	afterContext := flag.IntP("after-context", "A", 0, "also display the given number of entries, from any log, after each displayed entry")
+	beforeContext := flag.IntP("before-context", "B", 0, "also display the given number of entries, from any log, before each displayed entry")
+	context := flag.IntP("context", "C", 0, "also display the given number of entries, from any log, before and after each displayed entry")
+	contextTime := flag.Duration("context-time", 0, "also display the entries, from any log, within the given time of each displayed entry")
 	highlight := flag.StringP("highlight", "h", "", "highlight text that matches the regex")
+	outputFormat := flag.String("output", mergedlog.OUTPUT_TEXT, "output format. One of: "+strings.Join(mergedlog.OutputFormats, ", "))
+	columns := flag.StringSlice("columns", mergedlog.DefaultColumns, "columns of csv and tsv output. Any of: "+strings.Join(mergedlog.ColumnNames(), ", "))
 	profFile := flag.String("prof", "", "write profiling info to a file")
+	defaultFormat := flag.String("format", mergedlog.GemFireFormat.Name(), "log format of files without a 'format@' prefix. One of: "+strings.Join(mergedlog.LogFormatNames(), ", "))
+	formatsFile := flag.String("formats", "", "YAML file defining additional log formats")
+	timestampFormat := flag.String("timestamp-format", "", "force the timestamp layout instead of detecting it per file. One of: "+strings.Join(mergedlog.TimestampLayoutNames(), ", "))
+	offsets := flag.StringSlice("offset", nil, "clock skew correction added to the timestamps of a tag, for example 'server1=+250ms'. May be repeated")
+	showAdjusted := flag.Bool("show-adjusted", false, "show the adjusted timestamp of entries from logs with an --offset")
+	estimateSkew := flag.Bool("estimate-skew", false, "estimate the clock offsets between members from membership messages, report them and exit")
+	applySkew := flag.Bool("apply-skew", false, "estimate the clock offsets between members and apply them to the merge (explicit --offset values take precedence)")
+	timeZone := flag.String("tz", "", "rewrite entry timestamps in the given time zone, for example 'UTC' or 'America/New_York'")
+	zoneMappings := flag.StringSlice("zone-map", nil, "interpret a time zone abbreviation found in logs as the given zone, for example 'IST=Europe/Dublin'. May be repeated")
+	causalTies := flag.Bool("causal-ties", false, "order entries with identical timestamps using membership view IDs rather than file order")
+	follow := flag.BoolP("follow", "f", false, "keep reading the logs as they grow, after reading them from the start")
+	reorderWindow := flag.Duration("reorder-window", time.Second, "when following, how long to hold back an entry waiting for earlier entries from other logs")
+	includeFiles := flag.StringSlice("include-files", mergedlog.DefaultFileFilter.Include, "glob(s) selecting log files when walking directories or archives")
+	excludeFiles := flag.StringSlice("exclude-files", mergedlog.DefaultFileFilter.Exclude, "glob(s) of files to skip when walking directories or archives")
 
 	flag.Parse()
 
-	var rangeStart int64 = 0
-	var rangeStop = mergedlog.MAX_INT
-
-	if *rangeStartStr != "" {
-		t, err := time.Parse(mergedlog.STAMP_FORMAT, *rangeStartStr)
-		if err != nil {
-			log.Fatalf("Unable to parse '%s' as timestamp", *rangeStartStr)
+	for _, spec := range *zoneMappings {
+		abbreviation, name, err := mergedlog.ParseZoneMapping(spec)
+		if err == nil {
+			err = mergedlog.SetZoneAbbreviation(abbreviation, name)
 		}
-		rangeStart = t.UnixNano()
-
-		if *rangeStopStr == "" {
-			if *duration == mergedlog.MAX_INT {
-				rangeStop = mergedlog.MAX_INT
-			} else {
-				rangeStop = rangeStart + int64(time.Duration(*duration)*time.Second)
-			}
+		if err != nil {
+			log.Fatalf("Unable to use zone mapping: %s", err)
 		}
 	}
 
-	if *rangeStopStr != "" {
-		t, err := time.Parse(mergedlog.STAMP_FORMAT, *rangeStopStr)
+	location := time.Local
+	if *timeZone != "" {
+		var err error
+		location, err = time.LoadLocation(*timeZone)
 		if err != nil {
-			log.Fatalf("Unable to parse '%s' as timestamp", *rangeStopStr)
-		}
-		rangeStop = t.UnixNano()
-
-		if *rangeStartStr == "" {
-			if *duration == mergedlog.MAX_INT {
-				rangeStart = 0
-			} else {
-				rangeStart = rangeStop - int64(time.Duration(*duration)*time.Second)
-			}
+			log.Fatalf("Unknown time zone '%s': %s", *timeZone, err)
 		}
 	}
 
-	if *debugLevel > 0 {
-		fmt.Printf("---- DEBUG ===> rangeStart: %v\n", rangeStart)
-		fmt.Printf("---- DEBUG ===> rangeStop: %v\n", rangeStop)
-		fmt.Printf("---- DEBUG ===> calculated duration: %v\n", rangeStop-rangeStart)
-		fmt.Printf("---- DEBUG ===> duration: %v\n", *duration)
-		fmt.Printf("---- DEBUG ===> maxInt: %v\n", mergedlog.MAX_INT)
+	timeRange, err := mergedlog.ParseTimeRange(*rangeStartStr, *rangeStopStr, *duration, location)
+	if err != nil {
+		log.Fatalf("Unable to parse time range: %s", err)
+	}
+	if *around != "" {
+		err = timeRange.SetAround(*around, *window, location)
+	}
+	if err == nil && *aroundMatch != "" {
+		err = timeRange.SetAroundMatch(*aroundMatch, *window)
+	}
+	if err != nil {
+		log.Fatalf("Unable to parse time range: %s", err)
 	}
 
+	var grepFilter *mergedlog.GrepFilter
 	var grepRegex *regexp.Regexp
-	if *grep != "" {
-		grepRegex = mergedlog.MakeGrepRegex(*grep)
+	if len(*greps) > 0 || len(*excludes) > 0 {
+		grepFilter, err = mergedlog.NewGrepFilter(*greps, *excludes, *grepAll)
+		if err != nil {
+			log.Fatalf("Unable to parse grep expression: %s", err)
+		}
+		if len(*greps) > 0 {
+			grepRegex = mergedlog.MakeMultiGrepRegex(*greps)
+		}
 	}
 
 	var highlightRegex *regexp.Regexp
 	if *highlight != "" {
-		highlightRegex = regexp.MustCompile("(.*)(" + *highlight + ")(.*)")
+		highlightRegex, err = regexp.Compile("(.*)(" + *highlight + ")(.*)")
+		if err != nil {
+			log.Fatalf("Unable to parse highlight expression: %s", err)
+		}
 	}
 
-	processor := mergedlog.NewProcessor(rangeStart, rangeStop, grepRegex, highlightRegex, *debugLevel)
+	processor := mergedlog.NewProcessor(0, mergedlog.MAX_INT, grepRegex, highlightRegex, *debugLevel)
 	processor.SetWriter(bufio.NewWriterSize(os.Stdout, 65536))
+	if *follow {
+		processor.SetFollow(*reorderWindow)
+	}
+	if err := processor.SetOutputFormat(*outputFormat); err != nil {
+		log.Fatalf("%s", err)
+	}
+	if flag.CommandLine.Changed("columns") && *outputFormat != mergedlog.OUTPUT_CSV && *outputFormat != mergedlog.OUTPUT_TSV {
+		log.Fatalf("--columns can only be used with --output %s or %s", mergedlog.OUTPUT_CSV, mergedlog.OUTPUT_TSV)
+	}
+	if err := processor.SetColumns(*columns); err != nil {
+		log.Fatalf("Unable to use columns: %s", err)
+	}
+	processor.SetCausalTies(*causalTies)
+	if grepFilter != nil {
+		processor.SetGrepFilter(grepFilter)
+	}
+	if !flag.CommandLine.Changed("before-context") {
+		*beforeContext = *context
+	}
+	if !flag.CommandLine.Changed("after-context") {
+		*afterContext = *context
+	}
+	processor.SetContext(*beforeContext, *afterContext, *contextTime)
+	if *where != "" {
+		query, err := mergedlog.ParseQuery(*where)
+		if err != nil {
+			log.Fatalf("Unable to parse --where expression: %s", err)
+		}
+		processor.SetQuery(query)
+	}
+	if *level != "" {
+		filter, err := mergedlog.ParseLevelFilter(*level)
+		if err != nil {
+			log.Fatalf("Unable to parse level filter: %s", err)
+		}
+		processor.SetLevelFilter(filter)
+	}
+	if *thread != "" || len(*tids) > 0 {
+		filter, err := mergedlog.NewThreadFilter(*thread, *tids)
+		if err != nil {
+			log.Fatalf("Unable to parse thread filter: %s", err)
+		}
+		processor.SetThreadFilter(filter)
+	}
+	processor.SetShowAdjusted(*showAdjusted)
+	if *timeZone != "" {
+		processor.SetLocation(location)
+	}
+	var timestampLayout *mergedlog.TimestampLayout
+	if *timestampFormat != "" {
+		timestampLayout = mergedlog.FindTimestampLayout(*timestampFormat)
+		if timestampLayout == nil {
+			log.Fatalf("Unknown timestamp format '%s'", *timestampFormat)
+		}
+		processor.SetTimestampLayout(timestampLayout)
+	}
 
 	if userColor == "none" {
 		palette = make([]mergedlog.ColorFn, 1)
@@ -120,53 +210,171 @@ func main() {
 		processor.SetPalette(palette)
 	}
 
+	fileFilter := &mergedlog.FileFilter{
+		Include: *includeFiles,
+		Exclude: *excludeFiles,
+	}
+
+	if *formatsFile != "" {
+		if err := mergedlog.RegisterLogFormatsFile(*formatsFile); err != nil {
+			log.Fatalf("Unable to load formats: %s", err)
+		}
+	}
+
+	logFormat := mergedlog.FindLogFormat(*defaultFormat)
+	if logFormat == nil {
+		log.Fatalf("Unknown log format '%s'", *defaultFormat)
+	}
+
 	// Use an array so that we get consistent ordering of the files and thus consistent coloring across runs
-	filenameList := make([]string, len(flag.Args()))
+	var sourceList []*mergedlog.LogSource
+	sourceFormats := make(map[*mergedlog.LogSource]mergedlog.LogFormat)
 	fullToShort := make(map[string]string)
 	shortToTag := make(map[string]*string)
-	// Process the log filenames and potentially group them
-	for i, logTagName := range flag.Args() {
-		fullName, shortName, tag := mergedlog.ProcessFilename(logTagName, *fullAlias)
-		filenameList[i] = fullName
-		var maybeShort string
-		if *noLogRoll {
-			maybeShort = fullName
-		} else {
-			maybeShort = shortName
+	stdinSeen := false
+	// Process the log filenames, expand any archives and potentially group them
+	for _, arg := range flag.Args() {
+		format, logTagName := mergedlog.SplitFormatPrefix(arg)
+		if format == nil {
+			format = logFormat
 		}
-		fullToShort[fullName] = maybeShort
-		if _, present := shortToTag[maybeShort]; !present && tag != nil {
-			shortToTag[maybeShort] = tag
+		fullName, _, userTag := mergedlog.ProcessFilename(logTagName, *fullAlias)
+		if fullName == mergedlog.STDIN_NAME {
+			if stdinSeen {
+				log.Fatalf("Standard input ('%s') can only be given once", mergedlog.STDIN_NAME)
+			}
+			stdinSeen = true
+			if *estimateSkew || *applySkew {
+				log.Fatalf("Clock offsets cannot be estimated from standard input")
+			}
+			if timeRange.NeedsExtent() {
+				log.Fatalf("Times of day, times relative to the first or last entry and --around-match cannot be used with standard input")
+			}
+		}
+
+		sources, err := mergedlog.FindLogSources(fullName, fileFilter)
+		if err != nil {
+			log.Fatalf("Error opening file: %s", err)
+		}
+
+		for _, source := range sources {
+			sourceList = append(sourceList, source)
+			sourceFormats[source] = format
+			tag := source.Tag
+			if userTag != nil {
+				tag = userTag
+			}
+
+			var maybeShort string
+			if *noLogRoll {
+				maybeShort = source.Name
+			} else {
+				maybeShort = mergedlog.ShortenFilename(source.Name, *fullAlias)
+			}
+			fullToShort[source.Name] = maybeShort
+			if _, present := shortToTag[maybeShort]; !present && tag != nil {
+				shortToTag[maybeShort] = tag
+			}
 		}
 	}
 
-	var alias string
-	var maxNameLen = 0
-	// Gather our files and set up a Scanner for each of them
-	for _, full := range filenameList {
-		var rolled bool
+	// Determine the alias of a source and whether it is a rolled log
+	aliasOf := func(source *mergedlog.LogSource) (string, bool) {
+		full := source.Name
 		short := fullToShort[full]
 		if tag, ok := shortToTag[short]; ok {
-			alias = *tag
-		} else {
-			if *fullAlias {
-				alias = full
-			} else {
-				alias = short
+			return *tag, false
+		}
+
+		rolled := filepath.Base(full) != filepath.Base(short)
+		if *fullAlias {
+			return full, rolled
+		}
+		return short, rolled
+	}
+
+	if *estimateSkew || *applySkew {
+		estimator := mergedlog.NewSkewEstimator()
+		for _, source := range sourceList {
+			alias, _ := aliasOf(source)
+			f, err := source.Open()
+			if err != nil {
+				log.Fatalf("Error opening file: %s", err)
+			}
+			if err := estimator.AddLog(sourceFormats[source], alias, f, *maxBuffer); err != nil {
+				log.Fatalf("error reading '%s': %s", source.Name, err)
 			}
+			f.Close()
+		}
+
+		if !*applySkew {
+			estimator.Report(os.Stdout)
+			return
+		}
 
-			if filepath.Base(full) != filepath.Base(short) {
-				rolled = true
+		estimator.Report(os.Stderr)
+		for _, estimate := range estimator.Estimate() {
+			processor.SetOffset(estimate.Alias, estimate.Offset)
+		}
+	}
+
+	// Explicit offsets take precedence over estimated ones
+	for _, spec := range *offsets {
+		tag, offset, err := mergedlog.ParseOffset(spec)
+		if err != nil {
+			log.Fatalf("Unable to parse offset: %s", err)
+		}
+		processor.SetOffset(tag, offset)
+	}
+
+	var extent *mergedlog.LogExtent
+	if timeRange.NeedsExtent() {
+		extent = &mergedlog.LogExtent{Match: timeRange.AroundMatch, Layout: timestampLayout}
+		for _, source := range sourceList {
+			alias, _ := aliasOf(source)
+			f, err := source.Open()
+			if err != nil {
+				log.Fatalf("Error opening file: %s", err)
 			}
+			if err := extent.AddAdjustedLog(sourceFormats[source], processor.Offset(alias), f, *maxBuffer); err != nil {
+				log.Fatalf("error reading '%s': %s", source.Name, err)
+			}
+			f.Close()
 		}
+	}
+
+	rangeStart, rangeStop, err := timeRange.Resolve(extent)
+	if err != nil {
+		log.Fatalf("Unable to use time range: %s", err)
+	}
+	processor.SetRange(rangeStart, rangeStop)
 
-		f, err := os.Open(full)
+	if *debugLevel > 0 {
+		fmt.Printf("---- DEBUG ===> rangeStart: %v\n", rangeStart)
+		fmt.Printf("---- DEBUG ===> rangeStop: %v\n", rangeStop)
+		fmt.Printf("---- DEBUG ===> calculated duration: %v\n", rangeStop-rangeStart)
+		fmt.Printf("---- DEBUG ===> duration: %v\n", timeRange.Duration)
+		fmt.Printf("---- DEBUG ===> maxInt: %v\n", mergedlog.MAX_INT)
+	}
+
+	var maxNameLen = 0
+	// Gather our files and set up a Scanner for each of them
+	for _, source := range sourceList {
+		alias, rolled := aliasOf(source)
+
+		var f io.ReadCloser
+		var err error
+		if *follow {
+			f, err = source.Follow(followInterval)
+		} else {
+			f, err = source.Open()
+		}
 		if err != nil {
 			log.Fatalf("Error opening file: %s", err)
 		}
 		defer f.Close()
 
-		processor.AddLog(alias, rolled, f, *maxBuffer)
+		processor.AddNamedLog(sourceFormats[source], source.Name, alias, rolled, f, *maxBuffer)
 
 		if len(alias) > maxNameLen {
 			maxNameLen = len(alias)
@@ -174,6 +382,10 @@ func main() {
 	}
 	processor.SetFormat(maxNameLen)
 
+	if err := processor.CheckOffsets(); err != nil {
+		log.Fatalf("Unable to apply offset: %s", err)
+	}
+
 	if *profFile != "" {
 		// Start profiling
 		f, err := os.Create(*profFile)
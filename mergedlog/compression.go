@@ -0,0 +1,130 @@
+package mergedlog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	xzMagic    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	// bzip2BlockMagic follows the bzip2 magic and block size, unless the stream is empty and
+	// bzip2EndMagic follows instead
+	bzip2BlockMagic = []byte("1AY&SY")
+	bzip2EndMagic   = []byte{0x17, 0x72, 0x45, 0x38, 0x50, 0x90}
+)
+
+// sniffLength is the number of bytes needed to recognize any of the compression formats
+const sniffLength = 10
+
+// isBzip2 checks the bzip2 magic, the block size ('1' to '9') and the magic of the first block,
+// since "BZh" alone could easily start a plain log
+func isBzip2(magic []byte) bool {
+	if len(magic) < sniffLength || !bytes.HasPrefix(magic, bzip2Magic) || magic[3] < '1' || magic[3] > '9' {
+		return false
+	}
+	return bytes.Equal(magic[4:], bzip2BlockMagic) || bytes.Equal(magic[4:], bzip2EndMagic)
+}
+
+// Decompress sniffs the first few bytes of the reader and, if they look like gzip, bzip2, xz or
+// zstd data, wraps the reader in the appropriate decompressor. Any other content is returned
+// unchanged. The returned io.Closer releases any resources held by the decompressor; it does
+// not close the underlying reader.
+func Decompress(reader io.Reader) (io.Reader, io.Closer, error) {
+	buffered := bufio.NewReader(reader)
+	magic, err := buffered.Peek(sniffLength)
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, nil, fmt.Errorf("gzip: %w", err)
+		}
+		// Rolled logs are occasionally concatenated into a single .gz
+		gz.Multistream(true)
+		return gz, gz, nil
+	case isBzip2(magic):
+		return bzip2.NewReader(buffered), io.NopCloser(nil), nil
+	case bytes.HasPrefix(magic, xzMagic):
+		x, err := xz.NewReader(buffered)
+		if err != nil {
+			return nil, nil, fmt.Errorf("xz: %w", err)
+		}
+		return x, io.NopCloser(nil), nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		z, err := zstd.NewReader(buffered)
+		if err != nil {
+			return nil, nil, fmt.Errorf("zstd: %w", err)
+		}
+		return z, closerFunc(z.Close), nil
+	}
+
+	return buffered, io.NopCloser(nil), nil
+}
+
+// OpenLog opens the named file and transparently decompresses it if necessary.
+func OpenLog(name string) (io.ReadCloser, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, closer, err := Decompress(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return &logReader{Reader: reader, closers: []io.Closer{closer, f}}, nil
+}
+
+// logReader ties a (possibly decompressing) reader to the resources that need closing once
+// reading is done.
+type logReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *logReader) Close() error {
+	var firstErr error
+	for _, c := range r.closers {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+type closerFunc func()
+
+func (f closerFunc) Close() error {
+	f()
+	return nil
+}
+
+var compressionSuffixes = []string{".gz", ".bz2", ".xz", ".zst"}
+
+// trimCompressionSuffix removes a well-known compression extension, if present, so that
+// "server-01-02.log.gz" can be matched the same way as "server-01-02.log".
+func trimCompressionSuffix(name string) string {
+	for _, suffix := range compressionSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix)
+		}
+	}
+	return name
+}
@@ -0,0 +1,84 @@
+package mergedlog_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"merge-logs/mergedlog"
+
+	"github.com/klauspost/compress/zstd"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/ulikunitz/xz"
+)
+
+const compressedContent = "[info 2015/11/19 08:52:39.504 PST  line1\n[info 2015/11/19 08:52:39.505 PST  line2\n"
+
+func decompressAll(data []byte) string {
+	reader, closer, err := mergedlog.Decompress(bytes.NewReader(data))
+	Expect(err).ShouldNot(HaveOccurred())
+	defer closer.Close()
+
+	result, err := io.ReadAll(reader)
+	Expect(err).ShouldNot(HaveOccurred())
+	return string(result)
+}
+
+var _ = Describe("decompressing logs", func() {
+	It("passes plain text through unchanged", func() {
+		Expect(decompressAll([]byte(compressedContent))).To(Equal(compressedContent))
+	})
+
+	It("passes empty input through unchanged", func() {
+		Expect(decompressAll([]byte{})).To(Equal(""))
+	})
+
+	It("decompresses gzip data", func() {
+		buf := &bytes.Buffer{}
+		w := gzip.NewWriter(buf)
+		w.Write([]byte(compressedContent))
+		w.Close()
+
+		Expect(decompressAll(buf.Bytes())).To(Equal(compressedContent))
+	})
+
+	It("decompresses xz data", func() {
+		buf := &bytes.Buffer{}
+		w, err := xz.NewWriter(buf)
+		Expect(err).ShouldNot(HaveOccurred())
+		w.Write([]byte(compressedContent))
+		w.Close()
+
+		Expect(decompressAll(buf.Bytes())).To(Equal(compressedContent))
+	})
+
+	It("decompresses zstd data", func() {
+		buf := &bytes.Buffer{}
+		w, err := zstd.NewWriter(buf)
+		Expect(err).ShouldNot(HaveOccurred())
+		w.Write([]byte(compressedContent))
+		w.Close()
+
+		Expect(decompressAll(buf.Bytes())).To(Equal(compressedContent))
+	})
+
+	It("decompresses bzip2 data", func() {
+		// "hello\n" compressed with bzip2
+		data := []byte{
+			0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0xc1, 0xc0, 0x80, 0xe2,
+			0x00, 0x00, 0x01, 0x41, 0x00, 0x00, 0x10, 0x02, 0x44, 0xa0, 0x00, 0x30, 0xcd, 0x00,
+			0xc3, 0x46, 0x29, 0x97, 0x17, 0x72, 0x45, 0x38, 0x50, 0x90, 0xc1, 0xc0, 0x80, 0xe2,
+		}
+		Expect(decompressAll(data)).To(Equal("hello\n"))
+	})
+
+	It("decompresses an empty bzip2 stream", func() {
+		data := []byte{0x42, 0x5a, 0x68, 0x39, 0x17, 0x72, 0x45, 0x38, 0x50, 0x90, 0x00, 0x00, 0x00, 0x00}
+		Expect(decompressAll(data)).To(Equal(""))
+	})
+
+	It("passes plain text starting like bzip2 through unchanged", func() {
+		Expect(decompressAll([]byte("BZh9 is not a log level\n"))).To(Equal("BZh9 is not a log level\n"))
+		Expect(decompressAll([]byte("BZh"))).To(Equal("BZh"))
+	})
+})
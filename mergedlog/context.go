@@ -0,0 +1,72 @@
+package mergedlog
+
+import "time"
+
+// CONTEXT_SEPARATOR is written between groups of entries which are not contiguous when showing
+// context
+const CONTEXT_SEPARATOR = "--"
+
+// contextWindow decides, for merged entries arriving in order, which of the entries not selected
+// by the filters are shown as context around the selected ones. Context is chosen from the merged
+// entries of every log, so it spans members. An entry is shown as context if it is within the
+// given number of entries, or within the given time, of a selected entry.
+type contextWindow struct {
+	before int
+	after  int
+	within time.Duration
+
+	// pending holds the entries which may yet be shown before a selected entry
+	pending []*LogLine
+	// afterLeft and afterUntil limit the entries still to be shown after a selected entry
+	afterLeft  int
+	afterUntil int64
+	// skipped is set when an entry has been left out since the last entry shown
+	skipped bool
+	shown   bool
+}
+
+// offer passes the next merged entry through the window, calling emit for each entry to be shown
+// and separate before any entry which does not follow on from the previous one shown.
+func (w *contextWindow) offer(line *LogLine, emit func(*LogLine), separate func()) {
+	show := func(entry *LogLine) {
+		if w.skipped && w.shown {
+			separate()
+		}
+		emit(entry)
+		w.skipped = false
+		w.shown = true
+	}
+
+	if !line.isContext {
+		from := max(len(w.pending)-w.before, 0)
+		for from > 0 && w.within > 0 && w.pending[from-1].UTime >= line.UTime-int64(w.within) {
+			from--
+		}
+		if from > 0 {
+			w.skipped = true
+		}
+		for _, pending := range w.pending[from:] {
+			show(pending)
+		}
+		w.pending = w.pending[:0]
+
+		show(line)
+		w.afterLeft = w.after
+		w.afterUntil = line.UTime + int64(w.within)
+		return
+	}
+
+	if w.afterLeft > 0 || (w.within > 0 && line.UTime <= w.afterUntil) {
+		w.afterLeft--
+		show(line)
+		return
+	}
+
+	// Later selected entries cannot be earlier than this one, so older entries outside both the
+	// count and the time window can no longer be shown
+	w.pending = append(w.pending, line)
+	for len(w.pending) > w.before && (w.within == 0 || w.pending[0].UTime < line.UTime-int64(w.within)) {
+		w.pending = w.pending[1:]
+		w.skipped = true
+	}
+}
@@ -0,0 +1,85 @@
+package mergedlog
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Fields are the parts of a log entry parsed from its header. Fields which the entry's format
+// does not capture are left empty.
+type Fields struct {
+	Level Level
+	// Timestamp is the time as logged, before any clock skew correction
+	Timestamp time.Time
+	Member    string
+	Thread    string
+	TID       string
+	// Message is the entry's text following the header, including any following lines. If the
+	// format does not capture the message, it is the whole entry.
+	Message string
+}
+
+// ParseFields parses the fields of a single entry in the given format
+func ParseFields(format LogFormat, entry string) (Fields, error) {
+	header := format.Header()
+	matches := header.FindStringSubmatchIndex(entry)
+	if matches == nil {
+		return Fields{}, fmt.Errorf("no %s header found in '%s'", format.Name(), entry)
+	}
+
+	timestamps := timestampParser{layouts: format.Layouts()}
+	t, _, _, _, err := timestamps.parse(submatch(entry, matches, header.SubexpIndex("timestamp")))
+	if err != nil {
+		return Fields{}, err
+	}
+
+	fields := newHeaderFields(header).parse(entry, matches)
+	fields.Timestamp = t
+	return fields, nil
+}
+
+// headerFields holds the indices of the subexpressions of a format's header which capture
+// [Fields]. The subexpressions are named "level", "member", "thread", "tid" and "message".
+type headerFields struct {
+	level, member, thread, tid, message int
+}
+
+func newHeaderFields(header *regexp.Regexp) headerFields {
+	return headerFields{
+		level:   header.SubexpIndex("level"),
+		member:  header.SubexpIndex("member"),
+		thread:  header.SubexpIndex("thread"),
+		tid:     header.SubexpIndex("tid"),
+		message: header.SubexpIndex("message"),
+	}
+}
+
+// parse extracts the fields, other than the timestamp, from an entry given the indices returned
+// by the header's [regexp.Regexp.FindStringSubmatchIndex].
+func (h headerFields) parse(entry string, matches []int) Fields {
+	fields := Fields{
+		Member:  submatch(entry, matches, h.member),
+		Thread:  submatch(entry, matches, h.thread),
+		TID:     submatch(entry, matches, h.tid),
+		Message: entry,
+	}
+
+	if level := submatch(entry, matches, h.level); level != "" {
+		fields.Level, _ = ParseLevel(level)
+	}
+	if h.message >= 0 && matches[2*h.message] >= 0 {
+		fields.Message = entry[matches[2*h.message]:matches[2*h.message+1]]
+	}
+
+	return fields
+}
+
+// submatch returns the text of a subexpression, given the indices returned by
+// [regexp.Regexp.FindStringSubmatchIndex], or "" if it did not match or does not exist.
+func submatch(text string, matches []int, index int) string {
+	if index < 0 || matches[2*index] < 0 {
+		return ""
+	}
+	return text[matches[2*index]:matches[2*index+1]]
+}
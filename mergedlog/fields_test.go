@@ -0,0 +1,69 @@
+package mergedlog_test
+
+import (
+	"merge-logs/mergedlog"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("entry fields", func() {
+	It("parses GemFire headers", func() {
+		fields, err := mergedlog.ParseFields(mergedlog.GemFireFormat,
+			"[warning 2018/01/25 19:09:36.949 UTC server1 <P2P message reader for 10.0.0.1(server2:1234)<v1>:41000 shared unordered uid=3 port=5555> tid=0x4f] Membership: slow receiver\n\tat Foo.bar(Foo.java:1)")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(fields.Level).To(Equal(mergedlog.LevelWarning))
+		Expect(fields.Timestamp.UnixNano()).To(Equal(time.Date(2018, 1, 25, 19, 9, 36, 949000000, time.UTC).UnixNano()))
+		Expect(fields.Member).To(Equal("server1"))
+		Expect(fields.Thread).To(Equal("P2P message reader for 10.0.0.1(server2:1234)<v1>:41000 shared unordered uid=3 port=5555"))
+		Expect(fields.TID).To(Equal("0x4f"))
+		Expect(fields.Message).To(Equal("Membership: slow receiver\n\tat Foo.bar(Foo.java:1)"))
+	})
+
+	It("ends the thread at the end of the header", func() {
+		fields, err := mergedlog.ParseFields(mergedlog.GemFireFormat,
+			"[info 2018/01/25 19:09:36.949 UTC server1 <main> tid=0x1] got Foo<Bar>]")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(fields.Thread).To(Equal("main"))
+		Expect(fields.TID).To(Equal("0x1"))
+		Expect(fields.Message).To(Equal("got Foo<Bar>]"))
+
+		fields, err = mergedlog.ParseFields(mergedlog.GemFireFormat,
+			"[info 2018/01/25 19:09:36.949 UTC server1 <main>] got Foo<Bar>]")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(fields.Thread).To(Equal("main"))
+		Expect(fields.Message).To(Equal("got Foo<Bar>]"))
+	})
+
+	It("parses GemFire headers without a member", func() {
+		fields, err := mergedlog.ParseFields(mergedlog.GemFireFormat,
+			"[info 2023-05-01T10:11:12.345+0000 <main> tid=0x1] Starting")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(fields.Member).To(BeEmpty())
+		Expect(fields.Thread).To(Equal("main"))
+		Expect(fields.TID).To(Equal("0x1"))
+		Expect(fields.Message).To(Equal("Starting"))
+	})
+
+	It("uses the whole entry as the message of an incomplete header", func() {
+		fields, err := mergedlog.ParseFields(mergedlog.GemFireFormat, "[info 2015/11/19 08:52:39.504 PST  a1")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(fields.Level).To(Equal(mergedlog.LevelInfo))
+		Expect(fields.Thread).To(BeEmpty())
+		Expect(fields.Message).To(Equal("[info 2015/11/19 08:52:39.504 PST  a1"))
+	})
+
+	It("parses the fields captured by other formats", func() {
+		fields, err := mergedlog.ParseFields(mergedlog.ISO8601Format, "2023-05-01 10:11:12.345 UTC  WARN 1234 --- [main] c.e.App : slow")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(fields.Level).To(Equal(mergedlog.LevelWarning))
+		Expect(fields.Timestamp.UnixNano()).To(Equal(time.Date(2023, 5, 1, 10, 11, 12, 345000000, time.UTC).UnixNano()))
+		Expect(fields.Member).To(BeEmpty())
+	})
+
+	It("fails for entries without a header", func() {
+		_, err := mergedlog.ParseFields(mergedlog.GemFireFormat, "at Foo.bar(Foo.java:1)")
+		Expect(err).Should(HaveOccurred())
+	})
+})
@@ -0,0 +1,191 @@
+package mergedlog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// followReader provides `tail -f` semantics over a log file. Instead of returning io.EOF it
+// polls for more data, starting again from the beginning if the file is truncated and
+// switching to the new file if the log is rolled (GemFire renames the current log to
+// "name-01-02.log" and starts a fresh file under the original name).
+type followReader struct {
+	name     string
+	file     *os.File
+	interval time.Duration
+	closed   atomic.Bool
+}
+
+// FollowLog opens the named file for following. Reads block until more data is written to the
+// log, polling every interval, and only end once the returned reader is closed.
+func FollowLog(name string, interval time.Duration) (io.ReadCloser, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &followReader{
+		name:     name,
+		file:     f,
+		interval: interval,
+	}, nil
+}
+
+func (r *followReader) Read(p []byte) (int, error) {
+	for {
+		if r.closed.Load() {
+			r.file.Close()
+			return 0, io.EOF
+		}
+
+		n, err := r.file.Read(p)
+		if n > 0 {
+			return n, nil
+		}
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		reopened, err := r.checkRotation()
+		if err != nil {
+			return 0, fmt.Errorf("following %s: %w", r.name, err)
+		}
+		if !reopened {
+			time.Sleep(r.interval)
+		}
+	}
+}
+
+// checkRotation is called once the current file has been read to the end. It returns true if
+// reading should restart immediately because the file was truncated or replaced.
+func (r *followReader) checkRotation() (bool, error) {
+	current, err := r.file.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	offset, err := r.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return false, err
+	}
+
+	if current.Size() < offset {
+		_, err = r.file.Seek(0, io.SeekStart)
+		return true, err
+	}
+
+	latest, err := os.Stat(r.name)
+	if err != nil {
+		// The log may be in the middle of being rolled, try again later
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if os.SameFile(current, latest) {
+		return false, nil
+	}
+
+	f, err := os.Open(r.name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	r.file.Close()
+	r.file = f
+
+	return true, nil
+}
+
+func (r *followReader) Close() error {
+	r.closed.Store(true)
+	return nil
+}
+
+// followFlush is injected into a followed log once it has been idle, marking the end of the
+// entry being held back. It cannot appear in a log.
+var followFlush = []byte("\x00merge-logs:flush\x00")
+
+// idleReader passes on what is read from a followed log. Entries are only split off once the
+// next entry has started, so once no more data has arrived for the idle time it injects
+// [followFlush], letting the newest entry of a quiet log through.
+type idleReader struct {
+	chunks  chan []byte
+	err     error
+	pending []byte
+	idle    time.Duration
+	// flushed is set when nothing has been read since followFlush was last injected
+	flushed bool
+}
+
+func newIdleReader(reader io.Reader, idle time.Duration) *idleReader {
+	r := &idleReader{chunks: make(chan []byte), idle: idle, flushed: true}
+	go func() {
+		for {
+			buf := make([]byte, 32*1024)
+			n, err := reader.Read(buf)
+			if n > 0 {
+				r.chunks <- buf[:n]
+			}
+			if err != nil {
+				r.err = err
+				close(r.chunks)
+				return
+			}
+		}
+	}()
+	return r
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	if len(r.pending) == 0 {
+		var timeout <-chan time.Time
+		if !r.flushed {
+			timeout = time.After(r.idle)
+		}
+
+		select {
+		case chunk, ok := <-r.chunks:
+			if !ok {
+				return 0, r.err
+			}
+			r.pending = chunk
+			r.flushed = false
+		case <-timeout:
+			r.pending = followFlush
+			r.flushed = true
+		}
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// splitFollowed splits a followed log with the format's [bufio.SplitFunc], except that an entry
+// followed by [followFlush] is returned straight away rather than once the next entry starts
+func (lf *LogFile) splitFollowed(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	flush := bytes.Index(data, followFlush)
+	if flush < 0 {
+		advance, token, err = lf.format.Split(data, atEOF)
+		if token != nil {
+			lf.cut = false
+		}
+		return advance, token, err
+	}
+	if flush == 0 {
+		return len(followFlush), nil, nil
+	}
+
+	advance, token, err = lf.format.Split(data[:flush], true)
+	lf.cut = advance == flush
+	return advance, token, err
+}
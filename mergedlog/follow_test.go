@@ -0,0 +1,197 @@
+package mergedlog_test
+
+import (
+	"bufio"
+	"io"
+	"merge-logs/mergedlog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// readChunk reads whatever is next available from a following reader
+func readChunk(reader io.Reader) string {
+	result := make(chan string, 1)
+	go func() {
+		buf := make([]byte, 1024)
+		n, _ := reader.Read(buf)
+		result <- string(buf[:n])
+	}()
+
+	var chunk string
+	Eventually(result).Should(Receive(&chunk))
+	return chunk
+}
+
+func appendToFile(name string, content string) {
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	Expect(err).ShouldNot(HaveOccurred())
+	defer f.Close()
+	_, err = f.WriteString(content)
+	Expect(err).ShouldNot(HaveOccurred())
+}
+
+var _ = Describe("following logs", func() {
+	var name string
+
+	BeforeEach(func() {
+		name = filepath.Join(GinkgoT().TempDir(), "server.log")
+		appendToFile(name, "first\n")
+	})
+
+	It("keeps reading as the log grows", func() {
+		reader, err := mergedlog.FollowLog(name, 10*time.Millisecond)
+		Expect(err).ShouldNot(HaveOccurred())
+		defer reader.Close()
+
+		Expect(readChunk(reader)).To(Equal("first\n"))
+		appendToFile(name, "second\n")
+		Expect(readChunk(reader)).To(Equal("second\n"))
+	})
+
+	It("restarts from the beginning when the log is truncated", func() {
+		reader, err := mergedlog.FollowLog(name, 10*time.Millisecond)
+		Expect(err).ShouldNot(HaveOccurred())
+		defer reader.Close()
+
+		Expect(readChunk(reader)).To(Equal("first\n"))
+		Expect(os.Truncate(name, 0)).To(Succeed())
+		appendToFile(name, "new\n")
+		Expect(readChunk(reader)).To(Equal("new\n"))
+	})
+
+	It("switches to the new log when the log is rolled", func() {
+		reader, err := mergedlog.FollowLog(name, 10*time.Millisecond)
+		Expect(err).ShouldNot(HaveOccurred())
+		defer reader.Close()
+
+		Expect(readChunk(reader)).To(Equal("first\n"))
+		Expect(os.Rename(name, strings.Replace(name, ".log", "-01-01.log", 1))).To(Succeed())
+		appendToFile(name, "rolled\n")
+		Expect(readChunk(reader)).To(Equal("rolled\n"))
+	})
+
+	It("stops reading once closed", func() {
+		reader, err := mergedlog.FollowLog(name, 10*time.Millisecond)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(readChunk(reader)).To(Equal("first\n"))
+		Expect(reader.Close()).To(Succeed())
+
+		_, err = reader.Read(make([]byte, 10))
+		Expect(err).To(Equal(io.EOF))
+	})
+
+	It("merges entries arriving late from a slower log", func() {
+		processor := mergedlog.NewProcessor(0, mergedlog.MAX_INT, nilRegex, nilRegex, 0)
+		processor.SetPalette(noopPalette)
+		result := &safeBuilder{}
+		processor.SetWriter(result)
+		processor.SetFollow(time.Hour)
+
+		fastReader, fast := io.Pipe()
+		slowReader, slow := io.Pipe()
+		processor.AddLog("fast", false, fastReader, bufio.MaxScanTokenSize)
+		processor.AddLog("slow", false, slowReader, bufio.MaxScanTokenSize)
+		processor.SetFormat(4)
+
+		done := make(chan bool)
+		go func() {
+			processor.Crank()
+			close(done)
+		}()
+
+		io.WriteString(fast, "[info 2015/11/19 08:52:39.502 PST  fast1\n[info 2015/11/19 08:52:39.504 PST  fast2\n")
+		Consistently(result.String, "200ms").Should(BeEmpty())
+
+		io.WriteString(slow, "[info 2015/11/19 08:52:39.503 PST  slow1\n")
+		fast.Close()
+		slow.Close()
+
+		Eventually(done).Should(BeClosed())
+		Expect(strings.Split(strings.TrimSpace(result.String()), "\n")).To(Equal([]string{
+			"[fast] [info 2015/11/19 08:52:39.502 PST  fast1",
+			"[slow] [info 2015/11/19 08:52:39.503 PST  slow1",
+			"[fast] [info 2015/11/19 08:52:39.504 PST  fast2",
+		}))
+	})
+
+	It("emits entries once the reorder window has passed", func() {
+		processor := mergedlog.NewProcessor(0, mergedlog.MAX_INT, nilRegex, nilRegex, 0)
+		processor.SetPalette(noopPalette)
+		result := &safeBuilder{}
+		processor.SetWriter(result)
+		processor.SetFollow(10 * time.Millisecond)
+
+		fastReader, fast := io.Pipe()
+		slowReader, slow := io.Pipe()
+		processor.AddLog("fast", false, fastReader, bufio.MaxScanTokenSize)
+		processor.AddLog("slow", false, slowReader, bufio.MaxScanTokenSize)
+		processor.SetFormat(4)
+
+		go processor.Crank()
+
+		io.WriteString(fast, "[info 2015/11/19 08:52:39.502 PST  fast1\n[info 2015/11/19 08:52:39.504 PST  fast2\n")
+		Eventually(result.String).Should(Equal("[fast] [info 2015/11/19 08:52:39.502 PST  fast1\n"))
+
+		fast.Close()
+		slow.Close()
+	})
+
+	It("emits the last entry of a quiet log", func() {
+		processor := mergedlog.NewProcessor(0, mergedlog.MAX_INT, nilRegex, nilRegex, 0)
+		processor.SetPalette(noopPalette)
+		result := &safeBuilder{}
+		processor.SetWriter(result)
+		processor.SetFollow(10 * time.Millisecond)
+
+		appendToFile(name, "[info 2015/11/19 08:52:39.502 PST  entry1\n[info 2015/11/19 08:52:39.504 PST  entry2\n")
+		reader, err := mergedlog.FollowLog(name, 10*time.Millisecond)
+		Expect(err).ShouldNot(HaveOccurred())
+		defer reader.Close()
+		processor.AddLog("a", false, reader, bufio.MaxScanTokenSize)
+		processor.SetFormat(1)
+
+		go processor.Crank()
+
+		Eventually(result.String).Should(Equal("[a] [info 2015/11/19 08:52:39.502 PST  entry1\n" +
+			"[a] [info 2015/11/19 08:52:39.504 PST  entry2\n"))
+
+		// The rest of an entry written after it was emitted still follows it
+		appendToFile(name, "\tat Foo.bar(Foo.java:1)\n[info 2015/11/19 08:52:39.506 PST  entry3\n")
+		Eventually(result.String).Should(HaveSuffix("[a] [info 2015/11/19 08:52:39.504 PST  entry2\n" +
+			"[a] \tat Foo.bar(Foo.java:1)\n" +
+			"[a] [info 2015/11/19 08:52:39.506 PST  entry3\n"))
+	})
+
+	It("ignores a newline written after the last entry was emitted", func() {
+		processor := mergedlog.NewProcessor(0, mergedlog.MAX_INT, nilRegex, nilRegex, 0)
+		processor.SetPalette(noopPalette)
+		result := &safeBuilder{}
+		processor.SetWriter(result)
+		processor.SetFollow(10 * time.Millisecond)
+
+		appendToFile(name, "[info 2015/11/19 08:52:39.502 PST  entry1\n[info 2015/11/19 08:52:39.504 PST  entry2")
+		reader, err := mergedlog.FollowLog(name, 10*time.Millisecond)
+		Expect(err).ShouldNot(HaveOccurred())
+		defer reader.Close()
+		processor.AddLog("a", false, reader, bufio.MaxScanTokenSize)
+		processor.SetFormat(1)
+
+		go processor.Crank()
+
+		Eventually(result.String).Should(HaveSuffix("entry2\n"))
+
+		// The newline is flushed on its own before the next entry is written
+		appendToFile(name, "\n")
+		time.Sleep(100 * time.Millisecond)
+		appendToFile(name, "[info 2015/11/19 08:52:39.506 PST  entry3\n")
+		Eventually(result.String).Should(Equal("[a] [info 2015/11/19 08:52:39.502 PST  entry1\n" +
+			"[a] [info 2015/11/19 08:52:39.504 PST  entry2\n" +
+			"[a] [info 2015/11/19 08:52:39.506 PST  entry3\n"))
+	})
+})
@@ -0,0 +1,197 @@
+package mergedlog
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// LogFormat describes how to find and parse the entries of a particular kind of log.
+type LogFormat interface {
+	// Name identifies the format, for example in the "format@tag:path" file prefix
+	Name() string
+	// Split is a [bufio.SplitFunc] which returns one whole, possibly multi-line, log entry per
+	// token.
+	Split(data []byte, atEOF bool) (advance int, token []byte, err error)
+	// Header matches the first line of an entry. Its "timestamp" subexpression must capture the
+	// text starting with the entry's timestamp. Optional "level", "member", "thread", "tid" and
+	// "message" subexpressions capture the entry's other [Fields].
+	Header() *regexp.Regexp
+	// Layouts are the timestamp layouts this format may use, in the order they are tried when
+	// detecting the layout of a file.
+	Layouts() []*TimestampLayout
+}
+
+// PatternFormat is a [LogFormat] defined by regular expressions.
+type PatternFormat struct {
+	name     string
+	boundary *regexp.Regexp
+	header   *regexp.Regexp
+	layouts  []*TimestampLayout
+}
+
+// NewPatternFormat creates a format whose entries begin at the start of any line matching
+// entryStart. The header must contain a "timestamp" subexpression capturing the text which
+// starts with a timestamp in one of the given layouts.
+func NewPatternFormat(name string, entryStart string, header string, layouts []*TimestampLayout) (*PatternFormat, error) {
+	boundary, err := regexp.Compile(`\n(?:` + entryStart + `)`)
+	if err != nil {
+		return nil, fmt.Errorf("format %s: invalid entry start: %w", name, err)
+	}
+
+	headerRE, err := regexp.Compile(header)
+	if err != nil {
+		return nil, fmt.Errorf("format %s: invalid header: %w", name, err)
+	}
+	if headerRE.SubexpIndex("timestamp") < 0 {
+		return nil, fmt.Errorf("format %s: header has no (?P<timestamp>...) subexpression", name)
+	}
+
+	if len(layouts) == 0 {
+		return nil, fmt.Errorf("format %s: no timestamp layouts", name)
+	}
+
+	return &PatternFormat{
+		name:     name,
+		boundary: boundary,
+		header:   headerRE,
+		layouts:  layouts,
+	}, nil
+}
+
+func mustPatternFormat(name string, entryStart string, header string, layouts ...string) *PatternFormat {
+	var l []*TimestampLayout
+	for _, layoutName := range layouts {
+		layout := FindTimestampLayout(layoutName)
+		if layout == nil {
+			panic("unknown timestamp layout " + layoutName)
+		}
+		l = append(l, layout)
+	}
+
+	f, err := NewPatternFormat(name, entryStart, header, l)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func (f *PatternFormat) Name() string {
+	return f.name
+}
+
+func (f *PatternFormat) Header() *regexp.Regexp {
+	return f.header
+}
+
+func (f *PatternFormat) Layouts() []*TimestampLayout {
+	return f.layouts
+}
+
+func (f *PatternFormat) Split(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	return scanEntries(f.boundary, data, atEOF)
+}
+
+// scanEntries splits data into entries, each ending where the boundary (a newline followed by
+// the start of the next entry) matches.
+func scanEntries(boundary *regexp.Regexp, data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	if loc := boundary.FindIndex(data); loc != nil {
+		// We have a full newline-terminated entry.
+		return loc[0] + 1, bytes.TrimRight(data[0:loc[0]], "\r"), nil
+	}
+
+	// If we're at EOF, we have a final, non-terminated entry. Return it,
+	// dropping the trailing newline.
+	if atEOF {
+		return len(data), bytes.TrimRight(data[0:], "\r\n"), nil
+	}
+
+	// Request more data.
+	return 0, nil, nil
+}
+
+var (
+	// GemFireFormat is the format of GemFire and Geode logs: "[level timestamp member <thread> tid=0x1] message"
+	GemFireFormat = mustPatternFormat("gemfire",
+		`\[\w`,
+		`^\[(?P<level>\w+) (?P<timestamp>\d{4}[/-]\d{2}[/-]\d{2}[T ]\S+(?: [A-Z]{2,5}\b)?)`+
+			`(?: *(?P<member>[^<\]\n]*?) *(?:<(?P<thread>[^\n]*?)>)? *(?:tid=(?P<tid>\w+))?\](?s: ?(?P<message>.*)))?`,
+		"gemfire", "log4j2", "iso8601", "iso8601-zone", "iso8601-local")
+
+	// ISO8601Format matches logs whose entries start with an ISO-8601 timestamp, such as the
+	// default Spring Boot layout: "2023-05-01 10:11:12.345  INFO 1234 --- [main] ..."
+	ISO8601Format = mustPatternFormat("iso8601",
+		`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}`,
+		`(?m)^(?P<timestamp>\d{4}-\d{2}-\d{2}[T ].*?)(?:\s+(?P<level>TRACE|DEBUG|INFO|WARN(?:ING)?|ERROR|FATAL)\b.*)?$`,
+		"log4j2", "iso8601", "iso8601-zone", "iso8601-local")
+
+	// SyslogFormat matches RFC 3164 ("<34>Oct 11 22:14:15 host app: ...") and RFC 5424
+	// ("<34>1 2003-10-11T22:14:15.003Z host app ...") messages, with or without the priority.
+	SyslogFormat = mustPatternFormat("syslog",
+		`(?:<\d+>)?(?:1 )?(?:[A-Z][a-z]{2} [ \d]\d |\d{4}-\d{2}-\d{2}T)`,
+		`^(?:<\d+>)?(?:1 )?(?P<timestamp>.*)`,
+		"rfc3164", "iso8601")
+
+	// ApacheFormat matches the Apache combined (and common) log format:
+	// `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 ...`
+	ApacheFormat = mustPatternFormat("apache",
+		`\S`,
+		`^\S+ \S+ .*?\[(?P<timestamp>[^\]]+)\]`,
+		"apache")
+)
+
+var logFormats = map[string]LogFormat{}
+
+func init() {
+	for _, f := range []LogFormat{GemFireFormat, ISO8601Format, SyslogFormat, ApacheFormat} {
+		logFormats[f.Name()] = f
+	}
+}
+
+// RegisterLogFormat makes a format available by name. It is an error if a format, including a
+// built-in format, already has the same name.
+func RegisterLogFormat(format LogFormat) error {
+	if _, ok := logFormats[format.Name()]; ok {
+		return fmt.Errorf("format %s is already defined", format.Name())
+	}
+	logFormats[format.Name()] = format
+	return nil
+}
+
+// FindLogFormat returns the named format or nil if there is no such format
+func FindLogFormat(name string) LogFormat {
+	return logFormats[name]
+}
+
+// LogFormatNames returns the names of all registered formats, sorted
+func LogFormatNames() []string {
+	names := make([]string, 0, len(logFormats))
+	for name := range logFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SplitFormatPrefix splits a "format@[tag:]file" input into the format and the remaining
+// "[tag:]file". If there is no prefix naming a registered format, the returned format is nil
+// and the input is returned unchanged.
+func SplitFormatPrefix(name string) (LogFormat, string) {
+	prefix, rest, found := strings.Cut(name, "@")
+	if !found {
+		return nil, name
+	}
+
+	format := FindLogFormat(prefix)
+	if format == nil {
+		return nil, name
+	}
+
+	return format, rest
+}
@@ -0,0 +1,128 @@
+package mergedlog_test
+
+import (
+	"bufio"
+	"merge-logs/mergedlog"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// splitAll splits the text into entries using the format
+func splitAll(format mergedlog.LogFormat, text string) []string {
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Split(format.Split)
+
+	var entries []string
+	for scanner.Scan() {
+		entries = append(entries, scanner.Text())
+	}
+	Expect(scanner.Err()).ShouldNot(HaveOccurred())
+	return entries
+}
+
+// parseTime parses the timestamp of an entry using the first matching layout of the format
+func parseTime(format mergedlog.LogFormat, entry string) time.Time {
+	matches := format.Header().FindStringSubmatch(entry)
+	Expect(matches).ToNot(BeNil())
+
+	text := matches[format.Header().SubexpIndex("timestamp")]
+	for _, layout := range format.Layouts() {
+		if t, err := layout.Parse(text); err == nil {
+			return t
+		}
+	}
+	Fail("no layout matched " + text)
+	return time.Time{}
+}
+
+var _ = Describe("log formats", func() {
+	It("splits and parses ISO-8601 logs", func() {
+		entries := splitAll(mergedlog.ISO8601Format, `2023-05-01 10:11:12.345 UTC  INFO 1234 --- [main] c.e.App : started
+2023-05-01T10:11:13.345Z ERROR 1234 --- [main] c.e.App : failed
+java.lang.RuntimeException: boom
+	at c.e.App.main(App.java:10)`)
+
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[1]).To(HaveSuffix("(App.java:10)"))
+		Expect(parseTime(mergedlog.ISO8601Format, entries[0]).UnixNano()).To(Equal(time.Date(2023, 5, 1, 10, 11, 12, 345000000, time.UTC).UnixNano()))
+		Expect(parseTime(mergedlog.ISO8601Format, entries[1]).UnixNano()).To(Equal(time.Date(2023, 5, 1, 10, 11, 13, 345000000, time.UTC).UnixNano()))
+	})
+
+	It("splits and parses syslog messages", func() {
+		entries := splitAll(mergedlog.SyslogFormat, `<34>1 2003-10-11T22:14:15.003Z mymachine su - ID47 - 'su root' failed
+Oct  1 22:14:15 mymachine su: 'su root' failed
+<13>Oct 11 22:14:16 mymachine app: started`)
+
+		Expect(entries).To(HaveLen(3))
+		Expect(parseTime(mergedlog.SyslogFormat, entries[0]).UnixNano()).To(Equal(time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC).UnixNano()))
+
+		t := parseTime(mergedlog.SyslogFormat, entries[1])
+		Expect(t.Month()).To(Equal(time.October))
+		Expect(t.Day()).To(Equal(1))
+		Expect(t.Hour()).To(Equal(22))
+		Expect(t.Before(time.Now().Add(24 * time.Hour))).To(BeTrue())
+
+		Expect(parseTime(mergedlog.SyslogFormat, entries[2]).Day()).To(Equal(11))
+	})
+
+	It("splits and parses Apache combined logs", func() {
+		entries := splitAll(mergedlog.ApacheFormat, `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08"
+127.0.0.1 - - [10/Oct/2000:13:55:37 -0700] "GET / HTTP/1.0" 200 100 "-" "-"`)
+
+		Expect(entries).To(HaveLen(2))
+		Expect(parseTime(mergedlog.ApacheFormat, entries[0]).UnixNano()).To(Equal(time.Date(2000, 10, 10, 20, 55, 36, 0, time.UTC).UnixNano()))
+	})
+
+	Context("splitting the format prefix", func() {
+		It("returns a registered format", func() {
+			format, rest := mergedlog.SplitFormatPrefix("syslog@host:/var/log/messages")
+			Expect(format).To(Equal(mergedlog.SyslogFormat))
+			Expect(rest).To(Equal("host:/var/log/messages"))
+		})
+
+		It("ignores names without a prefix", func() {
+			format, rest := mergedlog.SplitFormatPrefix("tag:server.log")
+			Expect(format).To(BeNil())
+			Expect(rest).To(Equal("tag:server.log"))
+		})
+
+		It("ignores prefixes which are not formats", func() {
+			format, rest := mergedlog.SplitFormatPrefix("dir@2023/server.log")
+			Expect(format).To(BeNil())
+			Expect(rest).To(Equal("dir@2023/server.log"))
+		})
+	})
+
+	It("rejects pattern formats without a time subexpression", func() {
+		_, err := mergedlog.NewPatternFormat("bad", `\d`, `^(\d+)`, mergedlog.TimestampLayouts)
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("merges logs of different formats", func() {
+		processor := mergedlog.NewProcessor(0, mergedlog.MAX_INT, nilRegex, nilRegex, 0)
+		processor.SetPalette(noopPalette)
+		result := &strings.Builder{}
+		processor.SetWriter(result)
+
+		gemfire := `[info 2023/05/01 10:11:12.345 UTC server1 <main> tid=0x1] gemfire1
+[info 2023/05/01 10:11:12.348 UTC server1 <main> tid=0x1] gemfire2`
+		app := `2023-05-01 10:11:12.346 UTC  INFO 1234 --- [main] c.e.App : app`
+		syslog := `<34>1 2023-05-01T10:11:12.347Z host su - - - syslog`
+
+		processor.AddFormattedLog(mergedlog.GemFireFormat, "gf", false, strings.NewReader(gemfire), bufio.MaxScanTokenSize)
+		processor.AddFormattedLog(mergedlog.ISO8601Format, "ap", false, strings.NewReader(app), bufio.MaxScanTokenSize)
+		processor.AddFormattedLog(mergedlog.SyslogFormat, "sl", false, strings.NewReader(syslog), bufio.MaxScanTokenSize)
+		processor.SetFormat(2)
+		processor.Crank()
+
+		Expect(strings.Split(strings.TrimSpace(result.String()), "\n")).To(Equal([]string{
+			"[gf] [info 2023/05/01 10:11:12.345 UTC server1 <main> tid=0x1] gemfire1",
+			"[ap] 2023-05-01 10:11:12.346 UTC  INFO 1234 --- [main] c.e.App : app",
+			"[sl] <34>1 2023-05-01T10:11:12.347Z host su - - - syslog",
+			"[gf] [info 2023/05/01 10:11:12.348 UTC server1 <main> tid=0x1] gemfire2",
+		}))
+	})
+})
@@ -0,0 +1,104 @@
+package mergedlog
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// FormatConfig describes a user-defined log format, as read from a YAML formats file:
+//
+//	formats:
+//	  - name: myapp
+//	    entry-start: '\d{4}-\d{2}-\d{2} '
+//	    header: '^(?P<timestamp>\S+ \S+) +(?P<level>\w+) \[(?P<thread>[^\]]*)\] (?P<message>.*)'
+//	    time-layout: '2006-01-02 15:04:05.000'
+//
+// The header must have a "timestamp" capture group. The optional "level", "member", "thread",
+// "tid" and "message" groups fill in the [Fields] of each entry. Instead of time-layout,
+// time-layouts may list the names of built-in timestamp layouts (see [TimestampLayouts]) to
+// detect from.
+type FormatConfig struct {
+	Name        string   `yaml:"name"`
+	EntryStart  string   `yaml:"entry-start"`
+	Header      string   `yaml:"header"`
+	TimeLayout  string   `yaml:"time-layout"`
+	TimeLayouts []string `yaml:"time-layouts"`
+}
+
+type formatsFile struct {
+	Formats []FormatConfig `yaml:"formats"`
+}
+
+// Build creates the format described by the configuration
+func (c *FormatConfig) Build() (LogFormat, error) {
+	if c.Name == "" {
+		return nil, fmt.Errorf("format has no name")
+	}
+	if c.EntryStart == "" {
+		return nil, fmt.Errorf("format %s: no entry-start", c.Name)
+	}
+	if c.Header == "" {
+		return nil, fmt.Errorf("format %s: no header", c.Name)
+	}
+
+	var layouts []*TimestampLayout
+	if c.TimeLayout != "" {
+		layouts = append(layouts, NewTimestampLayout(c.Name, c.TimeLayout))
+	}
+	for _, name := range c.TimeLayouts {
+		layout := FindTimestampLayout(name)
+		if layout == nil {
+			return nil, fmt.Errorf("format %s: unknown timestamp layout '%s'", c.Name, name)
+		}
+		layouts = append(layouts, layout)
+	}
+
+	return NewPatternFormat(c.Name, c.EntryStart, c.Header, layouts)
+}
+
+// LoadLogFormats reads format definitions from YAML. See [FormatConfig].
+func LoadLogFormats(reader io.Reader) ([]LogFormat, error) {
+	var config formatsFile
+	decoder := yaml.NewDecoder(reader)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	formats := make([]LogFormat, 0, len(config.Formats))
+	for _, c := range config.Formats {
+		format, err := c.Build()
+		if err != nil {
+			return nil, err
+		}
+		formats = append(formats, format)
+	}
+
+	return formats, nil
+}
+
+// RegisterLogFormatsFile loads the formats defined in the named YAML file and registers them so
+// that they can be selected by name in the same way as the built-in formats.
+func RegisterLogFormatsFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	formats, err := LoadLogFormats(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	for _, format := range formats {
+		if err := RegisterLogFormat(format); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package mergedlog_test
+
+import (
+	"bufio"
+	"merge-logs/mergedlog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const formatsYAML = `
+formats:
+  - name: inhouse
+    entry-start: '\d{4}-\d{2}-\d{2} '
+    header: '^(?P<timestamp>\S+ \S+ [A-Z]+) +(?P<level>\w+) \[(?P<thread>[^\]]*)\] (?P<message>.*)'
+    time-layout: '2006-01-02 15:04:05.000 MST'
+  - name: detected
+    entry-start: '\w'
+    header: '^\w+ (?P<timestamp>.*)'
+    time-layouts: [gemfire, log4j2]
+`
+
+var _ = Describe("user-defined log formats", func() {
+	It("loads formats from YAML", func() {
+		formats, err := mergedlog.LoadLogFormats(strings.NewReader(formatsYAML))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(formats).To(HaveLen(2))
+		Expect(formats[0].Name()).To(Equal("inhouse"))
+		Expect(formats[1].Layouts()).To(Equal([]*mergedlog.TimestampLayout{
+			mergedlog.FindTimestampLayout("gemfire"),
+			mergedlog.FindTimestampLayout("log4j2"),
+		}))
+	})
+
+	It("rejects unknown fields", func() {
+		_, err := mergedlog.LoadLogFormats(strings.NewReader("formats:\n  - name: x\n    colour: red\n"))
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("rejects invalid regular expressions", func() {
+		_, err := mergedlog.LoadLogFormats(strings.NewReader("formats:\n  - name: x\n    entry-start: '('\n    header: '^(?P<timestamp>.*)'\n    time-layout: '2006'\n"))
+		Expect(err).Should(MatchError(ContainSubstring("invalid entry start")))
+	})
+
+	It("rejects unknown timestamp layouts", func() {
+		_, err := mergedlog.LoadLogFormats(strings.NewReader("formats:\n  - name: x\n    entry-start: 'x'\n    header: '^(?P<timestamp>.*)'\n    time-layouts: [nope]\n"))
+		Expect(err).Should(MatchError(ContainSubstring("unknown timestamp layout")))
+	})
+
+	It("refuses to replace a format which is already defined", func() {
+		name := filepath.Join(GinkgoT().TempDir(), "formats.yaml")
+		Expect(os.WriteFile(name, []byte("formats:\n  - name: gemfire\n    entry-start: 'x'\n    header: '^(?P<timestamp>.*)'\n    time-layout: '2006'\n"), 0644)).To(Succeed())
+		Expect(mergedlog.RegisterLogFormatsFile(name)).To(MatchError(ContainSubstring("format gemfire is already defined")))
+		Expect(mergedlog.FindLogFormat("gemfire")).To(BeIdenticalTo(mergedlog.GemFireFormat))
+	})
+
+	It("registers formats from a file for use in merging", func() {
+		name := filepath.Join(GinkgoT().TempDir(), "formats.yaml")
+		Expect(os.WriteFile(name, []byte(formatsYAML), 0644)).To(Succeed())
+		Expect(mergedlog.RegisterLogFormatsFile(name)).To(Succeed())
+
+		format, rest := mergedlog.SplitFormatPrefix("inhouse@app:app.log")
+		Expect(format).ToNot(BeNil())
+		Expect(rest).To(Equal("app:app.log"))
+
+		processor := mergedlog.NewProcessor(0, mergedlog.MAX_INT, nilRegex, nilRegex, 0)
+		processor.SetPalette(noopPalette)
+		result := &strings.Builder{}
+		processor.SetWriter(result)
+
+		app := `2023-05-01 10:11:12.346 UTC INFO [main] app1
+  continued
+2023-05-01 10:11:12.348 UTC WARN [main] app2`
+		gemfire := `[info 2023/05/01 10:11:12.347 UTC server1 <main> tid=0x1] gemfire`
+
+		processor.AddFormattedLog(format, "ap", false, strings.NewReader(app), bufio.MaxScanTokenSize)
+		processor.AddLog("gf", false, strings.NewReader(gemfire), bufio.MaxScanTokenSize)
+		processor.SetFormat(2)
+		processor.Crank()
+
+		Expect(strings.Split(strings.TrimSpace(result.String()), "\n")).To(Equal([]string{
+			"[ap] 2023-05-01 10:11:12.346 UTC INFO [main] app1",
+			"[ap]   continued",
+			"[gf] [info 2023/05/01 10:11:12.347 UTC server1 <main> tid=0x1] gemfire",
+			"[ap] 2023-05-01 10:11:12.348 UTC WARN [main] app2",
+		}))
+	})
+})
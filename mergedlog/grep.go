@@ -0,0 +1,85 @@
+package mergedlog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// GrepFilter selects entries by their text. Patterns are matched against each line of an entry
+// and an entry matches a pattern if any of its lines do.
+type GrepFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+	// all requires every include pattern to match, rather than any of them
+	all bool
+}
+
+// NewGrepFilter creates a filter selecting entries which match any of the include patterns (or
+// all of them, if matchAll is set) and none of the exclude patterns. With no include patterns
+// every entry not excluded is selected.
+func NewGrepFilter(include []string, exclude []string, matchAll bool) (*GrepFilter, error) {
+	filter := &GrepFilter{all: matchAll}
+
+	for _, pattern := range include {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("grep: %w", err)
+		}
+		filter.include = append(filter.include, re)
+	}
+
+	for _, pattern := range exclude {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("exclude: %w", err)
+		}
+		filter.exclude = append(filter.exclude, re)
+	}
+
+	return filter, nil
+}
+
+// Matches reports whether the entry is selected
+func (f *GrepFilter) Matches(entry string) bool {
+	lines := strings.Split(entry, "\n")
+
+	for _, re := range f.exclude {
+		if matchesAnyLine(re, lines) {
+			return false
+		}
+	}
+
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, re := range f.include {
+		matched := matchesAnyLine(re, lines)
+		if matched && !f.all {
+			return true
+		}
+		if !matched && f.all {
+			return false
+		}
+	}
+	return f.all
+}
+
+func matchesAnyLine(re *regexp.Regexp, lines []string) bool {
+	for _, line := range lines {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// MakeMultiGrepRegex returns the regex used to mark the text matching any of the patterns. See
+// [MakeGrepRegex].
+func MakeMultiGrepRegex(patterns []string) *regexp.Regexp {
+	alternatives := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		alternatives[i] = "(?:" + pattern + ")"
+	}
+	return MakeGrepRegex(strings.Join(alternatives, "|"))
+}
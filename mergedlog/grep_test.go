@@ -0,0 +1,53 @@
+package mergedlog_test
+
+import (
+	"merge-logs/mergedlog"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("grep filters", func() {
+	view := "[info 2015/11/19 08:52:39.504 PST  Membership: received new view\nView[locator|2] members: [locator, server1]"
+	heartbeat := "[fine 2015/11/19 08:52:39.600 PST  Membership: sending heartbeat to server1"
+	other := "[info 2015/11/19 08:52:39.700 PST  Cache server started"
+
+	It("selects entries matching any pattern", func() {
+		filter, err := mergedlog.NewGrepFilter([]string{"Membership", "started"}, nil, false)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(filter.Matches(view)).To(BeTrue())
+		Expect(filter.Matches(heartbeat)).To(BeTrue())
+		Expect(filter.Matches(other)).To(BeTrue())
+	})
+
+	It("selects entries matching all patterns, on any of their lines", func() {
+		filter, err := mergedlog.NewGrepFilter([]string{"Membership", "^View"}, nil, true)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(filter.Matches(view)).To(BeTrue())
+		Expect(filter.Matches(heartbeat)).To(BeFalse())
+		Expect(filter.Matches(other)).To(BeFalse())
+	})
+
+	It("excludes entries matching any exclude pattern", func() {
+		filter, err := mergedlog.NewGrepFilter([]string{"Membership"}, []string{"heartbeat", "suspect"}, false)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(filter.Matches(view)).To(BeTrue())
+		Expect(filter.Matches(heartbeat)).To(BeFalse())
+		Expect(filter.Matches(other)).To(BeFalse())
+	})
+
+	It("selects every entry not excluded when there are no include patterns", func() {
+		filter, err := mergedlog.NewGrepFilter(nil, []string{"heartbeat"}, false)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(filter.Matches(view)).To(BeTrue())
+		Expect(filter.Matches(heartbeat)).To(BeFalse())
+		Expect(filter.Matches(other)).To(BeTrue())
+	})
+
+	It("rejects invalid patterns", func() {
+		_, err := mergedlog.NewGrepFilter([]string{"Membership("}, nil, false)
+		Expect(err).Should(HaveOccurred())
+		_, err = mergedlog.NewGrepFilter(nil, []string{"[heartbeat"}, false)
+		Expect(err).Should(HaveOccurred())
+	})
+})
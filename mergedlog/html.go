@@ -0,0 +1,231 @@
+package mergedlog
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// HTMLSink writes a self-contained HTML report. See [OUTPUT_HTML].
+type HTMLSink struct {
+	writer io.Writer
+}
+
+// NewHTMLSink creates an [HTMLSink] writing to writer. The report is only complete once
+// [HTMLSink.End] has been called.
+func NewHTMLSink(writer io.Writer) *HTMLSink {
+	return &HTMLSink{writer: writer}
+}
+
+// Start writes the start of the report, up to the first entry
+func (s *HTMLSink) Start() {
+	fmt.Fprint(s.writer, htmlHead)
+}
+
+// Entry writes the entry as HTML in the color of its [ColorFn], with [GrepMatch] and
+// [HighlightMatch] text shown inverted. Multi-line entries are collapsed to their first line.
+func (s *HTMLSink) Entry(line *LogLine) {
+	color := cssColor(line.Color.Color)
+	lines := make([]string, len(line.Text))
+	for i, span := range line.Text {
+		lines[i] = spanToHTML(span, color)
+	}
+
+	class := "e"
+	if line.isContext {
+		class += " context"
+	}
+	alias := `<span class="alias">` + styledHTML(line.Alias, normalStyle(color)) + "</span> "
+	fmt.Fprintf(s.writer, `<div class="%s" data-alias="%s">`, class, html.EscapeString(strings.TrimSuffix(line.Alias, "*")))
+	if len(lines) == 1 {
+		fmt.Fprint(s.writer, alias, lines[0])
+	} else {
+		fmt.Fprint(s.writer, "<details><summary>", alias, lines[0], "</summary>",
+			strings.Join(lines[1:], "\n"), "</details>")
+	}
+	fmt.Fprintln(s.writer, "</div>")
+}
+
+func (s *HTMLSink) Separate() {
+	fmt.Fprintln(s.writer, "<hr>")
+}
+
+func (s *HTMLSink) Flush() {}
+
+// End writes the end of the report, including the script for searching and toggling aliases
+func (s *HTMLSink) End() {
+	fmt.Fprint(s.writer, htmlTail)
+}
+
+// spanToHTML renders the span as escaped HTML, styling plain text, [GrepMatch] and
+// [HighlightMatch] values with the CSS color
+func spanToHTML(span Span, color string) string {
+	var b strings.Builder
+	for _, value := range span {
+		switch text := value.(type) {
+		case string:
+			b.WriteString(styledHTML(text, normalStyle(color)))
+		case GrepMatch:
+			b.WriteString(styledHTML(string(text), matchStyle(color)))
+		case HighlightMatch:
+			b.WriteString(styledHTML(string(text), matchStyle(color)))
+		}
+	}
+	return b.String()
+}
+
+// styledHTML escapes the text, wrapping it in a span with the CSS style if there is one
+func styledHTML(text string, style string) string {
+	if text == "" || style == "" {
+		return html.EscapeString(text)
+	}
+	return fmt.Sprintf(`<span style="%s">%s</span>`, style, html.EscapeString(text))
+}
+
+// normalStyle is the CSS style of plain text in the CSS color, which may be empty
+func normalStyle(color string) string {
+	if color == "" {
+		return ""
+	}
+	return "color:" + color
+}
+
+// matchStyle is the CSS style of matched text, the inverse of [normalStyle]
+func matchStyle(color string) string {
+	if color == "" {
+		color = "var(--fg)"
+	}
+	return "color:var(--bg);background:" + color
+}
+
+// ansiColorNames are the names of the basic colors accepted by [MakePaletteEntry], in the order
+// of their ANSI codes
+var ansiColorNames = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+// cssColor returns the CSS color of the foreground of a [MakePaletteEntry] color, such as "64" or
+// "red+b", or "" if there is none
+func cssColor(color string) string {
+	color, _, _ = strings.Cut(color, ":")
+	color, _, _ = strings.Cut(color, "+")
+	if n, err := strconv.Atoi(color); err == nil {
+		return xtermColor(n)
+	}
+	for i, name := range ansiColorNames {
+		if color == name {
+			return xtermBasicColors[i]
+		}
+	}
+	return ""
+}
+
+// xtermBasicColors are the first 16 colors of the xterm 256 color palette
+var xtermBasicColors = []string{
+	"#000000", "#800000", "#008000", "#808000", "#000080", "#800080", "#008080", "#c0c0c0",
+	"#808080", "#ff0000", "#00ff00", "#ffff00", "#0000ff", "#ff00ff", "#00ffff", "#ffffff",
+}
+
+// xtermColor returns the CSS color of a color in the xterm 256 color palette
+func xtermColor(n int) string {
+	switch {
+	case n < 0 || n > 255:
+		return ""
+	case n < 16:
+		return xtermBasicColors[n]
+	case n < 232:
+		levels := []int{0, 95, 135, 175, 215, 255}
+		n -= 16
+		return fmt.Sprintf("#%02x%02x%02x", levels[n/36], levels[n/6%6], levels[n%6])
+	default:
+		gray := 8 + (n-232)*10
+		return fmt.Sprintf("#%02x%02x%02x", gray, gray, gray)
+	}
+}
+
+const htmlHead = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Merged logs</title>
+<style>
+:root { --bg: #002b36; --fg: #eee8d5; --rule: #586e75; }
+body.light { --bg: #fdf6e3; --fg: #073642; --rule: #93a1a1; }
+body { margin: 0; background: var(--bg); color: var(--fg); font-family: monospace; }
+#controls { position: sticky; top: 0; display: flex; flex-wrap: wrap; gap: 0.5em 1em; align-items: center;
+  padding: 0.5em; background: var(--bg); border-bottom: 1px solid var(--rule); font-family: sans-serif; }
+#aliases label { margin-right: 0.75em; white-space: nowrap; font-family: monospace; }
+#log { padding: 0.5em; }
+.e { white-space: pre-wrap; overflow-wrap: anywhere; }
+.e.context { opacity: 0.6; }
+.e details > summary { cursor: pointer; }
+hr { border: 0; border-top: 1px dashed var(--rule); }
+</style>
+</head>
+<body>
+<div id="controls">
+<input id="search" type="search" placeholder="Search" size="30">
+<button id="expand" type="button">Expand all</button>
+<button id="collapse" type="button">Collapse all</button>
+<button id="theme" type="button">Light/dark</button>
+<span id="aliases"></span>
+</div>
+<div id="log">
+`
+
+const htmlTail = `</div>
+<script>
+(function () {
+  var entries = Array.prototype.slice.call(document.querySelectorAll("#log .e"));
+  var hidden = {};
+  var search = document.getElementById("search");
+
+  function apply() {
+    var query = search.value.toLowerCase();
+    entries.forEach(function (e) {
+      e.hidden = hidden[e.dataset.alias] || (query !== "" && e.textContent.toLowerCase().indexOf(query) < 0);
+    });
+  }
+
+  var seen = {};
+  var aliases = document.getElementById("aliases");
+  entries.forEach(function (e) {
+    var alias = e.dataset.alias;
+    if (seen[alias]) {
+      return;
+    }
+    seen[alias] = true;
+    var label = document.createElement("label");
+    var toggle = document.createElement("input");
+    toggle.type = "checkbox";
+    toggle.checked = true;
+    toggle.addEventListener("change", function () {
+      hidden[alias] = !toggle.checked;
+      apply();
+    });
+    var name = document.createElement("span");
+    name.innerHTML = e.querySelector(".alias").innerHTML;
+    label.appendChild(toggle);
+    label.appendChild(name);
+    aliases.appendChild(label);
+  });
+
+  var timer;
+  search.addEventListener("input", function () {
+    clearTimeout(timer);
+    timer = setTimeout(apply, 200);
+  });
+
+  function expand(open) {
+    document.querySelectorAll("#log details").forEach(function (d) { d.open = open; });
+  }
+  document.getElementById("expand").addEventListener("click", function () { expand(true); });
+  document.getElementById("collapse").addEventListener("click", function () { expand(false); });
+  document.getElementById("theme").addEventListener("click", function () {
+    document.body.classList.toggle("light");
+  });
+})();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,93 @@
+package mergedlog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Level is the severity of a log entry. Levels are ordered, from [LevelFinest] to
+// [LevelFatal], and the zero value means the level is not known.
+type Level int
+
+const (
+	LevelUnknown Level = iota
+	LevelFinest
+	LevelFiner
+	LevelFine
+	LevelConfig
+	LevelInfo
+	LevelWarning
+	LevelError
+	LevelSevere
+	LevelFatal
+)
+
+var levelNames = []string{"unknown", "finest", "finer", "fine", "config", "info", "warning", "error", "severe", "fatal"}
+
+// levelAliases maps the names used by other logging frameworks to the equivalent GemFire level
+var levelAliases = map[string]Level{
+	"trace": LevelFinest,
+	"debug": LevelFine,
+	"warn":  LevelWarning,
+	"crit":  LevelSevere,
+}
+
+func (l Level) String() string {
+	if l < 0 || int(l) >= len(levelNames) {
+		return levelNames[LevelUnknown]
+	}
+	return levelNames[l]
+}
+
+// ParseLevel parses a GemFire level name, ignoring case. The Log4j names "trace", "debug" and
+// "warn" are also accepted.
+func ParseLevel(name string) (Level, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for i, levelName := range levelNames {
+		if i != int(LevelUnknown) && name == levelName {
+			return Level(i), nil
+		}
+	}
+	if level, ok := levelAliases[name]; ok {
+		return level, nil
+	}
+	return LevelUnknown, fmt.Errorf("unknown level '%s'; expected one of %s", name, strings.Join(levelNames[1:], ", "))
+}
+
+// LevelFilter selects entries by level
+type LevelFilter struct {
+	levels map[Level]bool
+	// minimum, if known, includes every level at least as severe
+	minimum Level
+}
+
+// ParseLevelFilter parses a comma separated list of levels. A level followed by "+" selects
+// that level and every more severe level. For example "warning+" or "config,error+".
+func ParseLevelFilter(spec string) (*LevelFilter, error) {
+	filter := &LevelFilter{levels: make(map[Level]bool)}
+
+	for _, part := range strings.Split(spec, ",") {
+		name, orMore := strings.CutSuffix(strings.TrimSpace(part), "+")
+		level, err := ParseLevel(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if !orMore {
+			filter.levels[level] = true
+		} else if filter.minimum == LevelUnknown || level < filter.minimum {
+			filter.minimum = level
+		}
+	}
+
+	return filter, nil
+}
+
+// Matches reports whether an entry with the level is selected. Entries of unknown level are
+// always selected, since they cannot be judged.
+func (f *LevelFilter) Matches(level Level) bool {
+	if level == LevelUnknown {
+		return true
+	}
+	return f.levels[level] || (f.minimum != LevelUnknown && level >= f.minimum)
+}
@@ -0,0 +1,55 @@
+package mergedlog_test
+
+import (
+	"merge-logs/mergedlog"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("levels", func() {
+	DescribeTable("parsing level names",
+		func(name string, expected mergedlog.Level) {
+			level, err := mergedlog.ParseLevel(name)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(level).To(Equal(expected))
+		},
+		Entry("gemfire", "fine", mergedlog.LevelFine),
+		Entry("upper case", "SEVERE", mergedlog.LevelSevere),
+		Entry("log4j warn", "WARN", mergedlog.LevelWarning),
+		Entry("log4j debug", "debug", mergedlog.LevelFine),
+	)
+
+	It("rejects unknown levels", func() {
+		_, err := mergedlog.ParseLevel("chatty")
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("selects a minimum level", func() {
+		filter, err := mergedlog.ParseLevelFilter("warning+")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(filter.Matches(mergedlog.LevelInfo)).To(BeFalse())
+		Expect(filter.Matches(mergedlog.LevelWarning)).To(BeTrue())
+		Expect(filter.Matches(mergedlog.LevelFatal)).To(BeTrue())
+	})
+
+	It("selects a set of levels", func() {
+		filter, err := mergedlog.ParseLevelFilter("config, severe+")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(filter.Matches(mergedlog.LevelConfig)).To(BeTrue())
+		Expect(filter.Matches(mergedlog.LevelInfo)).To(BeFalse())
+		Expect(filter.Matches(mergedlog.LevelError)).To(BeFalse())
+		Expect(filter.Matches(mergedlog.LevelSevere)).To(BeTrue())
+	})
+
+	It("selects entries of unknown level", func() {
+		filter, err := mergedlog.ParseLevelFilter("error")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(filter.Matches(mergedlog.LevelUnknown)).To(BeTrue())
+	})
+
+	It("rejects filters with unknown levels", func() {
+		_, err := mergedlog.ParseLevelFilter("info,loud+")
+		Expect(err).Should(HaveOccurred())
+	})
+})
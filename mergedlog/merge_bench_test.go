@@ -0,0 +1,76 @@
+package mergedlog
+
+import "testing"
+
+// The benchmarks in this file measure only the selection of the next entry, without reading or
+// parsing logs, comparing the [logHeap] used by crankAll with the linear scan it replaced.
+
+const benchMergeEntries = 100
+
+// makeMergeLogs creates logs whose already parsed entries interleave, so that every log is
+// repeatedly the source of the next entry
+func makeMergeLogs(count int) []*LogFile {
+	logFiles := make([]*LogFile, count)
+	for i := range logFiles {
+		logFile := &LogFile{index: i, logChannel: make(chan *LogLine, benchMergeEntries+1)}
+		for j := 0; j < benchMergeEntries; j++ {
+			logFile.logChannel <- &LogLine{UTime: int64(j*count + i)}
+		}
+		logFile.logChannel <- &LogLine{UTime: MAX_INT}
+		logFiles[i] = logFile
+	}
+	return logFiles
+}
+
+// mergeLinear is the merge used before the [logHeap], peeking every log for each entry
+func (this *Processor) mergeLinear() {
+	for {
+		idx := -1
+		oldestTimestamp := MAX_INT
+		for i, logFile := range this.logFiles {
+			if logFile.Peek().UTime < oldestTimestamp {
+				idx = i
+				oldestTimestamp = logFile.Peek().UTime
+			}
+		}
+
+		if idx < 0 {
+			break
+		}
+
+		this.output(this.logFiles[idx], this.logFiles[idx].Take())
+	}
+}
+
+func benchmarkMerge(b *testing.B, count int, merge func(*Processor)) {
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+		processor := &Processor{logFiles: makeMergeLogs(count), sink: NopSink{}}
+		b.StartTimer()
+		merge(processor)
+	}
+}
+
+func BenchmarkMergeHeap10(b *testing.B) {
+	benchmarkMerge(b, 10, (*Processor).crankAll)
+}
+
+func BenchmarkMergeHeap100(b *testing.B) {
+	benchmarkMerge(b, 100, (*Processor).crankAll)
+}
+
+func BenchmarkMergeHeap1000(b *testing.B) {
+	benchmarkMerge(b, 1000, (*Processor).crankAll)
+}
+
+func BenchmarkMergeLinear10(b *testing.B) {
+	benchmarkMerge(b, 10, (*Processor).mergeLinear)
+}
+
+func BenchmarkMergeLinear100(b *testing.B) {
+	benchmarkMerge(b, 100, (*Processor).mergeLinear)
+}
+
+func BenchmarkMergeLinear1000(b *testing.B) {
+	benchmarkMerge(b, 1000, (*Processor).mergeLinear)
+}
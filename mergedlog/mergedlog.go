@@ -2,8 +2,6 @@ package mergedlog
 
 import (
 	"bufio"
-	"bytes"
-	"fmt"
 	"log"
 	"regexp"
 	"strconv"
@@ -22,7 +20,31 @@ type LogFile struct {
 	index          int
 	logChannel     chan *LogLine
 	peek           *LogLine
-	Format         string
+	timestamps     timestampParser
+	format         LogFormat
+	causalTies     bool
+	viewClock      int64
+	// Offset corrects for clock skew and is added to every timestamp in the log
+	Offset time.Duration
+	// File is the name of the file the log is read from
+	File string
+	// location, if set, is the time zone entry timestamps are rewritten in
+	location *time.Location
+	// levelFilter, if set, selects entries by level
+	levelFilter *LevelFilter
+	// threadFilter, if set, selects entries by thread
+	threadFilter *ThreadFilter
+	// grepFilter, if set, selects entries by their text
+	grepFilter *GrepFilter
+	// query, if set, selects entries by their fields
+	query *Query
+	// keepContext passes on entries which are not selected, as context
+	keepContext bool
+	// cut is set by [LogFile.splitFollowed] when the last entry was returned before the next
+	// entry started, so more of it may follow
+	cut bool
+	// last is the last entry passed on, or nil if it was not selected
+	last *LogLine
 }
 
 type LogLine struct {
@@ -30,45 +52,201 @@ type LogLine struct {
 	UTime int64
 	Text  LogEntry
 	Color ColorFn
+	// File is the name of the file the entry was read from and LineNumber is the line, counting
+	// from 1, at which it starts
+	File       string
+	LineNumber int
+	// Raw is the entry's text without any markup
+	Raw string
+	// Fields are parsed from the entry's header
+	Fields Fields
+	// Offset is the clock skew correction of the entry's log, which is included in UTime
+	Offset time.Duration
+	// Adjusted is the entry's timestamp corrected by Offset, in the chosen time zone if any. See
+	// [Processor.SetLocation].
+	Adjusted time.Time
+	// layout is the layout the entry's timestamp was parsed with
+	layout *TimestampLayout
+	// arrived records when the line was read, used to bound the reorder window when following
+	arrived time.Time
+	// isContext is set for entries which were not selected by the filters, but are passed on to
+	// be shown as context around selected entries
+	isContext bool
+	// viewClock is the highest membership view ID seen in the log up to and including this line,
+	// less one if the line sends the view, used to break timestamp ties when causal tie breaking
+	// is enabled
+	viewClock int64
 }
 
 const MAX_INT = int64(^uint64(0) >> 1)
 
 func (lf *LogFile) Peek() *LogLine {
 	if lf.peek == nil {
-		lf.Take()
+		lf.peek = <-lf.logChannel
+	}
+	return lf.peek
+}
+
+// TryPeek is a non-blocking version of [LogFile.Peek]. It returns nil if no line is available yet.
+func (lf *LogFile) TryPeek() *LogLine {
+	if lf.peek == nil {
+		select {
+		case lf.peek = <-lf.logChannel:
+		default:
+		}
 	}
 	return lf.peek
 }
 
 func (lf *LogFile) Take() *LogLine {
-	taken := lf.peek
-	lf.peek = <-lf.logChannel
+	taken := lf.Peek()
+	lf.peek = nil
 	return taken
 }
 
-func (lf *LogFile) SetFormat(maxNameSize int) {
-	lf.Format = "%" + strconv.Itoa(len(lf.Alias)-maxNameSize) + "s[%s] "
+// before determines whether this log's next line should be emitted before the other log's next
+// line. Lines are ordered by timestamp, then by view clock (which is zero unless causal tie
+// breaking is enabled) and then by the order in which the logs were added. Lines from the same
+// log are always emitted in their original order.
+func (lf *LogFile) before(other *LogFile) bool {
+	a, b := lf.peek, other.peek
+	if a.UTime != b.UTime {
+		return a.UTime < b.UTime
+	}
+	if a.viewClock != b.viewClock {
+		return a.viewClock < b.viewClock
+	}
+	return lf.index < other.index
+}
+
+// membershipViewRE matches a GemFire membership view, capturing the view ID; for example:
+// View[7f6dea03(locator1:9238:locator)<ec><v0>:1024|1]
+var membershipViewRE = regexp.MustCompile(`View\[[^|\]]*\|(\d+)\]`)
+
+// viewSendRE matches an entry which sends or prepares a membership view; for example:
+// sending new view View[7f6dea03(locator1:9238:locator)<ec><v0>:1024|2]
+var viewSendRE = regexp.MustCompile(`(?i)\b(sending|preparing)\b.*View\[`)
+
+// trackView advances the log's view clock to the highest membership view mentioned in the entry
+// and returns the entry's clock. A member which has seen a later view must be causally after a
+// member which has not, so this serves as a hint when ordering entries with identical timestamps.
+// An entry sending or preparing a view comes before any member receives it, so it keeps the clock
+// of the view before.
+func (lf *LogFile) trackView(entry string) int64 {
+	previous := lf.viewClock
+	for _, m := range membershipViewRE.FindAllStringSubmatch(entry, -1) {
+		if id, err := strconv.ParseInt(m[1], 10, 64); err == nil && id > lf.viewClock {
+			lf.viewClock = id
+		}
+	}
+	if viewSendRE.MatchString(entry) {
+		return max(previous, lf.viewClock-1)
+	}
+	return lf.viewClock
+}
+
+// IsContext reports whether the entry was not selected by the filters, but is shown as context
+// around selected entries. See [Processor.SetContext].
+func (l *LogLine) IsContext() bool {
+	return l.isContext
 }
 
 func (lf *LogFile) Process() {
 	lineCount := 0
+	lineNumber := 1
+	previousCut := false
 	var grepMatch []string
 	var logChunk string
+	header := lf.format.Header()
+	timestampIndex := header.SubexpIndex("timestamp")
+	headerFields := newHeaderFields(header)
 
 	for {
 		if lf.Scanner.Scan() {
 			logChunk = lf.Scanner.Text()
+			entryLine := lineNumber
+			lineNumber += strings.Count(logChunk, "\n") + 1
+			afterCut := previousCut
+			previousCut = lf.cut
+			if logChunk == "" {
+				// When following, the newline ending an entry may be written after the entry was
+				// passed on, leaving nothing to add to it
+				previousCut = previousCut || afterCut
+				continue
+			}
 
-			matches := gfeLogLineRE.FindStringSubmatch(logChunk)
+			matches := header.FindStringSubmatchIndex(logChunk)
+			if matches == nil && afterCut {
+				// The rest of an entry which was passed on, when following, before it was complete
+				if lf.last != nil {
+					rest := *lf.last
+					rest.Text = LogEntry{}
+					for _, line := range strings.Split(logChunk, "\n") {
+						rest.Text = append(rest.Text, Span{line})
+					}
+					rest.LineNumber = entryLine
+					rest.Raw = logChunk
+					rest.arrived = time.Now()
+					lf.last = &rest
+					lf.logChannel <- &rest
+				}
+				continue
+			}
 			if matches == nil {
-				if lineCount == 0 || logChunk == "" {
+				if lineCount == 0 {
+					continue
+				}
+				// The format's Split should bring us a whole log entry chunk of text, but the
+				// format's entry start and header may not agree on every line.
+				log.Printf("No %s header found in entry from %s after entry %d: '%s'",
+					lf.format.Name(), lf.Alias, lineCount, logChunk)
+				continue
+			}
+
+			lf.last = nil
+			viewClock := lf.viewClock
+			if lf.causalTies {
+				viewClock = lf.trackView(logChunk)
+			}
+
+			stampText := submatch(logChunk, matches, timestampIndex)
+			t, layout, start, end, err := lf.timestamps.parse(stampText)
+			if err != nil {
+				log.Printf("Unable to parse date stamp in '%s': %s", lf.Alias, err)
+				continue
+			}
+			utime := t.UnixNano() + int64(lf.Offset)
+			if utime < lf.RangeStart || lf.RangeStop < utime {
+				continue
+			}
+
+			fields := headerFields.parse(logChunk, matches)
+			fields.Timestamp = t
+			adjusted := t.Add(lf.Offset)
+			if lf.location != nil {
+				adjusted = adjusted.In(lf.location)
+			}
+			// When keeping context, entries which are not selected are still passed on so that they
+			// can be shown around the selected entries of every log
+			selected := (lf.levelFilter == nil || lf.levelFilter.Matches(fields.Level)) &&
+				(lf.threadFilter == nil || lf.threadFilter.Matches(fields)) &&
+				(lf.query == nil || lf.query.Matches(strings.TrimSuffix(lf.Alias, "*"), fields))
+			if !selected && !lf.keepContext {
+				continue
+			}
+
+			if lf.location != nil {
+				// Rewrite the timestamp in the header so that every log reads in the same zone
+				stampStart := matches[2*timestampIndex] + start
+				logChunk = logChunk[:stampStart] + layout.Format(t.In(lf.location)) +
+					logChunk[stampStart+end-start:]
+			}
+
+			if lf.grepFilter != nil && !lf.grepFilter.Matches(logChunk) {
+				if !lf.keepContext {
 					continue
 				}
-				// This should not happen since the [ScanLogEntries] function should bring us
-				// a whole log entry chunk of text.
-				panic(fmt.Sprintf("No match for pattern on logChunk: '%s' file: %s line: %d",
-					logChunk, lf.Alias, lineCount))
+				selected = false
 			}
 
 			logEntry := LogEntry{}
@@ -81,7 +259,7 @@ func (lf *LogFile) Process() {
 					if grepMatch != nil {
 						foundGrep = true
 						span = append(span, grepMatch[1],
-							lf.Color.Grep(grepMatch[2]),
+							GrepMatch(grepMatch[2]),
 							grepMatch[len(grepMatch)-1])
 					} else {
 						span = append(span, line)
@@ -95,7 +273,10 @@ func (lf *LogFile) Process() {
 
 			// If we're grepping but didn't find anything in the whole log entry then move on
 			if lf.grepRegex != nil && !foundGrep {
-				continue
+				if !lf.keepContext {
+					continue
+				}
+				selected = false
 			}
 
 			lineCount++
@@ -111,7 +292,7 @@ func (lf *LogFile) Process() {
 								for n := 0; n < k; n++ {
 									newSpan = append(newSpan, span[n])
 								}
-								newSpan = append(newSpan, m[1], lf.Color.Highlight(m[2]), m[3])
+								newSpan = append(newSpan, m[1], HighlightMatch(m[2]), m[3])
 								for n := k + 1; n < len(span); n++ {
 									newSpan = append(newSpan, span[n])
 								}
@@ -122,23 +303,24 @@ func (lf *LogFile) Process() {
 				}
 			}
 
-			stamp := strings.TrimSpace(matches[1])
-			t, err := time.Parse(STAMP_FORMAT, stamp)
-			if err != nil {
-				log.Printf("Unable to parse date stamp '%s': %s", stamp, err)
-				continue
-			}
-			if t.UnixNano() < lf.RangeStart || lf.RangeStop < t.UnixNano() {
-				continue
-			}
-
 			l := &LogLine{
-				Alias: lf.Alias,
-				UTime: t.UnixNano(),
-				Text:  logEntry,
-				Color: lf.Color,
+				Alias:      lf.Alias,
+				UTime:      utime,
+				Text:       logEntry,
+				Color:      lf.Color,
+				File:       lf.File,
+				LineNumber: entryLine,
+				Raw:        logChunk,
+				Fields:     fields,
+				Offset:     lf.Offset,
+				Adjusted:   adjusted,
+				layout:     layout,
+				isContext:  !selected,
+				arrived:    time.Now(),
+				viewClock:  viewClock,
 			}
 
+			lf.last = l
 			lf.logChannel <- l
 
 		} else if err := lf.Scanner.Err(); err != nil {
@@ -154,24 +336,7 @@ func (lf *LogFile) Process() {
 	lf.logChannel <- endToken
 }
 
-var endOfLine = regexp.MustCompile(`\n\[\w`)
-
+// ScanLogEntries is a [bufio.SplitFunc] returning whole GemFire log entries. See [GemFireFormat].
 func ScanLogEntries(data []byte, atEOF bool) (advance int, token []byte, err error) {
-	if atEOF && len(data) == 0 {
-		return 0, nil, nil
-	}
-
-	if loc := endOfLine.FindIndex(data); loc != nil {
-		// We have a full newline-terminated line.
-		return loc[0] + 1, bytes.TrimRight(data[0:loc[0]], "\r"), nil
-	}
-
-	// If we're at EOF, we have a final, non-terminated line. Return it,
-	// dropping the trailing newline.
-	if atEOF {
-		return len(data), bytes.TrimRight(data[0:], "\r\n"), nil
-	}
-
-	// Request more data.
-	return 0, nil, nil
+	return GemFireFormat.Split(data, atEOF)
 }
@@ -4,6 +4,8 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -11,3 +13,21 @@ func TestMergedlog(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Mergedlog Suite")
 }
+
+// safeBuilder is a strings.Builder which can be read while a Processor is writing to it
+type safeBuilder struct {
+	mu      sync.Mutex
+	builder strings.Builder
+}
+
+func (b *safeBuilder) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.builder.Write(p)
+}
+
+func (b *safeBuilder) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.builder.String()
+}
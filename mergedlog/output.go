@@ -0,0 +1,163 @@
+package mergedlog
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// OUTPUT_TEXT writes each entry as text, prefixed with its alias and colored
+	OUTPUT_TEXT = "text"
+	// OUTPUT_JSON writes each entry as a JSON object on a line of its own (JSON Lines)
+	OUTPUT_JSON = "json"
+	// OUTPUT_CSV writes a row of comma separated values per entry, after a header row
+	OUTPUT_CSV = "csv"
+	// OUTPUT_TSV writes a row of tab separated values per entry, after a header row
+	OUTPUT_TSV = "tsv"
+	// OUTPUT_HTML writes a self-contained HTML report, in the colors of the palette, with
+	// collapsible multi-line entries, a toggle per alias and search
+	OUTPUT_HTML = "html"
+)
+
+// OutputFormats are the names of the supported output formats
+var OutputFormats = []string{OUTPUT_TEXT, OUTPUT_JSON, OUTPUT_CSV, OUTPUT_TSV, OUTPUT_HTML}
+
+// CSV_TIME_FORMAT is the format of the "time" column, which spreadsheets can parse
+const CSV_TIME_FORMAT = "2006-01-02 15:04:05.000"
+
+// DefaultColumns are the columns written to CSV and TSV output unless others are chosen
+var DefaultColumns = []string{"time", "alias", "level", "member", "thread", "tid", "message"}
+
+// csvColumn is a column available in CSV and TSV output
+type csvColumn struct {
+	name  string
+	value func(line *LogLine) string
+}
+
+// csvColumns are the columns available in CSV and TSV output, in the order they are listed
+var csvColumns = []csvColumn{
+	{"time", func(line *LogLine) string {
+		return line.Adjusted.Format(CSV_TIME_FORMAT)
+	}},
+	{"timestamp", func(line *LogLine) string {
+		return line.Adjusted.Format(time.RFC3339Nano)
+	}},
+	{"epoch", func(line *LogLine) string {
+		return strconv.FormatInt(line.UTime, 10)
+	}},
+	{"alias", func(line *LogLine) string {
+		return strings.TrimSuffix(line.Alias, "*")
+	}},
+	{"file", func(line *LogLine) string { return line.File }},
+	{"line", func(line *LogLine) string {
+		return strconv.Itoa(line.LineNumber)
+	}},
+	{"level", func(line *LogLine) string {
+		if line.Fields.Level == LevelUnknown {
+			return ""
+		}
+		return line.Fields.Level.String()
+	}},
+	{"member", func(line *LogLine) string { return line.Fields.Member }},
+	{"thread", func(line *LogLine) string { return line.Fields.Thread }},
+	{"tid", func(line *LogLine) string { return line.Fields.TID }},
+	{"message", func(line *LogLine) string { return line.Fields.Message }},
+	{"text", func(line *LogLine) string { return line.Raw }},
+	{"context", func(line *LogLine) string {
+		return strconv.FormatBool(line.isContext)
+	}},
+}
+
+// ColumnNames returns the names of the columns available in CSV and TSV output
+func ColumnNames() []string {
+	names := make([]string, len(csvColumns))
+	for i, column := range csvColumns {
+		names[i] = column.name
+	}
+	return names
+}
+
+// findColumn returns the column with the given name, or nil if there is none
+func findColumn(name string) *csvColumn {
+	for i := range csvColumns {
+		if csvColumns[i].name == name {
+			return &csvColumns[i]
+		}
+	}
+	return nil
+}
+
+// checkColumns validates the names of CSV and TSV columns
+func checkColumns(columns []string) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("no columns given")
+	}
+	for _, column := range columns {
+		if findColumn(column) == nil {
+			return fmt.Errorf("unknown column '%s'; expected any of %s", column, strings.Join(ColumnNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// CSVSink writes a header row and then a row of chosen columns per entry. Multi-line values are
+// quoted. See [OUTPUT_CSV] and [OUTPUT_TSV].
+type CSVSink struct {
+	writer  *csv.Writer
+	columns []string
+}
+
+// NewCSVSink creates a [CSVSink] writing the columns, separated by comma. See [ColumnNames].
+func NewCSVSink(writer io.Writer, columns []string, comma rune) (*CSVSink, error) {
+	if err := checkColumns(columns); err != nil {
+		return nil, err
+	}
+	csvWriter := csv.NewWriter(writer)
+	csvWriter.Comma = comma
+	return &CSVSink{writer: csvWriter, columns: columns}, nil
+}
+
+func (s *CSVSink) Start() {
+	s.writer.Write(s.columns)
+}
+
+func (s *CSVSink) Entry(line *LogLine) {
+	row := make([]string, len(s.columns))
+	for i, column := range s.columns {
+		row[i] = findColumn(column).value(line)
+	}
+	s.writer.Write(row)
+}
+
+// Separate does nothing, since context entries are marked by the "context" column instead
+func (s *CSVSink) Separate() {}
+
+func (s *CSVSink) Flush() {
+	s.writer.Flush()
+}
+
+func (s *CSVSink) End() {}
+
+// newSink creates the sink for the processor's output format
+func (this *Processor) newSink() Sink {
+	switch this.outputFormat {
+	case OUTPUT_JSON:
+		return NewJSONSink(this.writer)
+	case OUTPUT_CSV, OUTPUT_TSV:
+		comma := ','
+		if this.outputFormat == OUTPUT_TSV {
+			comma = '\t'
+		}
+		// The columns have already been checked by SetColumns
+		sink, _ := NewCSVSink(this.writer, this.columns, comma)
+		return sink
+	case OUTPUT_HTML:
+		return NewHTMLSink(this.writer)
+	default:
+		return NewTextSink(this.writer, this.aliasWidth, this.showAdjusted)
+	}
+}
@@ -2,9 +2,13 @@ package mergedlog
 
 import (
 	"bufio"
+	"container/heap"
 	"fmt"
 	"io"
 	"regexp"
+	"sort"
+	"strings"
+	"time"
 )
 
 type Processor struct {
@@ -20,24 +24,42 @@ type Processor struct {
 	grepRegex        *regexp.Regexp
 	highlightRegex   *regexp.Regexp
 	FileCount        int
+	follow           bool
+	reorderWindow    time.Duration
+	timestampLayout  *TimestampLayout
+	causalTies       bool
+	offsets          map[string]time.Duration
+	showAdjusted     bool
+	location         *time.Location
+	levelFilter      *LevelFilter
+	threadFilter     *ThreadFilter
+	grepFilter       *GrepFilter
+	query            *Query
+	context          *contextWindow
+	outputFormat     string
+	columns          []string
+	sink             Sink
+	aliasWidth       int
 }
 
 type ColorFn struct {
 	Normal    func(string) Highlighted
 	Grep      func(string) Highlighted
 	Highlight func(string) Highlighted
+	// Color is the color given to [MakePaletteEntry], if any, used to style output which has no
+	// ANSI codes, such as HTML
+	Color string
 }
 
 // Highlighted indicates a string that has been marked up with ANSI codes
 type Highlighted string
 
-// Span is a slice of either *string or [Highlighted] values and represents a line of text
+// Span is a slice of string, [GrepMatch] and [HighlightMatch] values and represents a line of
+// text. Plain text is a string. How each is rendered is up to the [Sink].
 type Span []any
 
 type LogEntry []Span
 
-var gfeLogLineRE = regexp.MustCompile(`^\[\w+ (([^ ]* ){3}).*`)
-
 const STAMP_FORMAT = "2006/01/02 15:04:05.000 MST"
 
 func NewProcessor(rangeStart, rangeStop int64, grepRegex, highlightRegex *regexp.Regexp, debugLevel int) *Processor {
@@ -46,16 +68,31 @@ func NewProcessor(rangeStart, rangeStop int64, grepRegex, highlightRegex *regexp
 	processor.rangeStart = rangeStart
 	processor.rangeStop = rangeStop
 	processor.aliasColorMap = make(map[string]int)
+	processor.offsets = make(map[string]time.Duration)
 	processor.palette = make([]ColorFn, 1)
 	processor.palette[0] = MakePaletteEntry("234")
 	processor.debugLevel = debugLevel
 	processor.grepRegex = grepRegex
 	processor.highlightRegex = highlightRegex
+	processor.outputFormat = OUTPUT_TEXT
+	processor.columns = DefaultColumns
 
 	return processor
 }
 
+// AddLog adds a GemFire log to be merged. See [Processor.AddFormattedLog].
 func (this *Processor) AddLog(alias string, rolled bool, reader io.Reader, maxBuffer int) {
+	this.AddFormattedLog(GemFireFormat, alias, rolled, reader, maxBuffer)
+}
+
+// AddFormattedLog adds a log, in the given format, to be merged. See [Processor.AddNamedLog].
+func (this *Processor) AddFormattedLog(format LogFormat, alias string, rolled bool, reader io.Reader, maxBuffer int) {
+	this.AddNamedLog(format, alias, alias, rolled, reader, maxBuffer)
+}
+
+// AddNamedLog adds a log, in the given format and read from the named file, to be merged.
+// Reading starts immediately in the background.
+func (this *Processor) AddNamedLog(format LogFormat, file string, alias string, rolled bool, reader io.Reader, maxBuffer int) {
 	if _, ok := this.aliasColorMap[alias]; !ok {
 		this.aliasColorMap[alias] = this.colorIndex
 		this.colorIndex = (this.colorIndex + 1) % len(this.palette)
@@ -76,10 +113,31 @@ func (this *Processor) AddLog(alias string, rolled bool, reader io.Reader, maxBu
 		highlightRegex: this.highlightRegex,
 		index:          this.FileCount,
 		logChannel:     make(chan *LogLine, 100),
+		timestamps: timestampParser{
+			layouts: format.Layouts(),
+			layout:  this.timestampLayout,
+			forced:  this.timestampLayout != nil,
+		},
+		format:       format,
+		causalTies:   this.causalTies,
+		Offset:       this.offsets[alias],
+		File:         file,
+		location:     this.location,
+		levelFilter:  this.levelFilter,
+		threadFilter: this.threadFilter,
+		grepFilter:   this.grepFilter,
+		query:        this.query,
+		keepContext:  this.context != nil,
 	}
 	this.FileCount++
 
-	logFile.Scanner.Split(ScanLogEntries)
+	if this.follow {
+		// Let the newest entry of a quiet log through once the reorder window has passed
+		logFile.Scanner = bufio.NewScanner(newIdleReader(reader, max(this.reorderWindow, followPollInterval)))
+		logFile.Scanner.Split(logFile.splitFollowed)
+	} else {
+		logFile.Scanner.Split(format.Split)
+	}
 	logFile.Scanner.Buffer(make([]byte, bufio.MaxScanTokenSize), maxBuffer)
 
 	if len(logFile.Alias) > this.maxLogNameLength {
@@ -90,52 +148,255 @@ func (this *Processor) AddLog(alias string, rolled bool, reader io.Reader, maxBu
 	go logFile.Process()
 }
 
+// SetFormat sets the width aliases are padded to in text output. See [NewTextSink].
 func (p *Processor) SetFormat(maxNameLen int) {
-	for _, logFile := range p.logFiles {
-		logFile.SetFormat(maxNameLen)
-	}
+	p.aliasWidth = maxNameLen
 }
 
+// followPollInterval is how long Crank waits for more input when following and nothing can be
+// emitted yet
+const followPollInterval = 50 * time.Millisecond
+
+// Crank merges the logs, writing each entry in timestamp order. Entries with the same
+// timestamp are written in the order their logs were added (unless causal tie breaking is
+// enabled, see [Processor.SetCausalTies]) and entries from the same log are always written in
+// their original order.
 func (this *Processor) Crank() {
-	var oldestTimestamp int64
-	var idx int
-	var line *LogLine
+	if this.sink == nil {
+		this.sink = this.newSink()
+	}
 
+	this.sink.Start()
+	if this.follow {
+		this.crankFollow()
+	} else {
+		this.crankAll()
+	}
+	this.sink.End()
+	this.flush()
+}
+
+// crankAll merges logs which have been completely written, using a [logHeap]
+func (this *Processor) crankAll() {
+	pending := make(logHeap, 0, len(this.logFiles))
+	for _, logFile := range this.logFiles {
+		if logFile.Peek().UTime != MAX_INT {
+			pending = append(pending, logFile)
+		}
+	}
+	heap.Init(&pending)
+
+	for len(pending) > 0 {
+		logFile := pending[0]
+		this.output(logFile, logFile.Take())
+
+		if logFile.Peek().UTime == MAX_INT {
+			heap.Pop(&pending)
+		} else {
+			heap.Fix(&pending, 0)
+		}
+	}
+}
+
+// crankFollow merges logs which are still being written. Since lines may not be available yet
+// this scans every log for each entry, rather than using a [logHeap]. An entry is only emitted
+// once every log has a pending entry, so that ordering can be decided, or once it has waited
+// for the reorder window; lines from slower members arriving within the window are thus still
+// merged into the correct place.
+func (this *Processor) crankFollow() {
 	for {
-		idx = -1
-		oldestTimestamp = MAX_INT
+		idx := -1
+		waiting := false
 		for i, logFile := range this.logFiles {
-			if logFile.Peek().UTime < oldestTimestamp {
+			line := logFile.TryPeek()
+			if line == nil {
+				waiting = true
+				continue
+			}
+			if line.UTime != MAX_INT && (idx < 0 || logFile.before(this.logFiles[idx])) {
 				idx = i
-				oldestTimestamp = logFile.Peek().UTime
 			}
 		}
 
-		if idx < 0 {
+		if idx < 0 && !waiting {
 			break
 		}
 
-		line = this.logFiles[idx].Take()
-
-		for _, logEntry := range line.Text {
-			fmt.Fprintf(this.writer, this.logFiles[idx].Format, "", line.Color.Normal(line.Alias))
-			for _, span := range logEntry {
-				switch s := span.(type) {
-				case Highlighted:
-					fmt.Fprint(this.writer, span)
-				case string:
-					fmt.Fprint(this.writer, line.Color.Normal(s))
-				}
-			}
-			fmt.Fprintln(this.writer)
+		if idx < 0 || (waiting && time.Since(this.logFiles[idx].Peek().arrived) < this.reorderWindow) {
+			this.flush()
+			time.Sleep(followPollInterval)
+			continue
 		}
+
+		this.output(this.logFiles[idx], this.logFiles[idx].Take())
 	}
 
+	this.flush()
+}
+
+// output passes a merged entry to the sink, unless context is being shown in which case the entry
+// is passed through the context window.
+func (this *Processor) output(logFile *LogFile, line *LogLine) {
+	if this.context == nil {
+		this.sink.Entry(line)
+		return
+	}
+	this.context.offer(line, this.sink.Entry, this.sink.Separate)
+}
+
+func (this *Processor) flush() {
+	this.sink.Flush()
 	if w, ok := this.writer.(*bufio.Writer); ok {
 		w.Flush()
 	}
 }
 
+// SetFollow puts the processor into follow mode: logs are expected to keep growing and entries
+// are emitted as they arrive, held back for at most reorderWindow to allow entries from other
+// logs with earlier timestamps to arrive. It should be called before
+// [Processor.SetOutputFormat].
+func (this *Processor) SetFollow(reorderWindow time.Duration) {
+	this.follow = true
+	this.reorderWindow = reorderWindow
+}
+
+// SetRange limits subsequently added logs to entries whose (adjusted) timestamps, as Unix
+// nanoseconds, fall within the range. See [TimeRange].
+func (this *Processor) SetRange(rangeStart, rangeStop int64) {
+	this.rangeStart = rangeStart
+	this.rangeStop = rangeStop
+}
+
+// SetOffset corrects for clock skew by adding the offset to every timestamp of logs
+// subsequently added with the given alias. The offset is applied before ordering and before
+// limiting the logs to the range.
+func (this *Processor) SetOffset(alias string, offset time.Duration) {
+	this.offsets[alias] = offset
+}
+
+// Offset returns the offset set for the alias, if any. See [Processor.SetOffset].
+func (this *Processor) Offset(alias string) time.Duration {
+	return this.offsets[alias]
+}
+
+// CheckOffsets returns an error if an offset was set for an alias which none of the added logs
+// has, which is most likely a mistyped alias
+func (this *Processor) CheckOffsets() error {
+	var unknown []string
+	for alias := range this.offsets {
+		if _, ok := this.aliasColorMap[alias]; !ok {
+			unknown = append(unknown, alias)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	sort.Strings(unknown)
+	aliases := make([]string, 0, len(this.aliasColorMap))
+	for alias := range this.aliasColorMap {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	return fmt.Errorf("no log has the tag %s; expected one of %s", strings.Join(unknown, ", "), strings.Join(aliases, ", "))
+}
+
+// SetShowAdjusted enables showing the adjusted timestamp, before the original entry, for entries
+// from logs with an offset.
+func (this *Processor) SetShowAdjusted(enabled bool) {
+	this.showAdjusted = enabled
+}
+
+// SetLocation sets the time zone in which the timestamps of subsequently added logs are
+// written. A nil location leaves timestamps as they were logged.
+func (this *Processor) SetLocation(location *time.Location) {
+	this.location = location
+}
+
+// SetLevelFilter limits subsequently added logs to entries selected by the filter. A nil filter
+// selects every entry.
+func (this *Processor) SetLevelFilter(filter *LevelFilter) {
+	this.levelFilter = filter
+}
+
+// SetThreadFilter limits subsequently added logs to entries selected by the filter. A nil
+// filter selects every entry.
+func (this *Processor) SetThreadFilter(filter *ThreadFilter) {
+	this.threadFilter = filter
+}
+
+// SetGrepFilter limits subsequently added logs to entries selected by the filter. This is in
+// addition to the grep regex given to [NewProcessor], which also marks the matching text.
+func (this *Processor) SetGrepFilter(filter *GrepFilter) {
+	this.grepFilter = filter
+}
+
+// SetQuery limits subsequently added logs to entries selected by the query. A nil query selects
+// every entry.
+func (this *Processor) SetQuery(query *Query) {
+	this.query = query
+}
+
+// SetContext shows entries not selected by the filters (such as the grep regex) if they are
+// within before entries before, or after entries after, a selected entry, or within the given
+// time of one. Context is taken from the merged entries of all logs. Groups of entries which do
+// not follow on from each other are separated by [CONTEXT_SEPARATOR]. Applies to subsequently
+// added logs.
+func (this *Processor) SetContext(before, after int, within time.Duration) {
+	if before <= 0 && after <= 0 && within <= 0 {
+		this.context = nil
+		return
+	}
+	this.context = &contextWindow{
+		before: max(before, 0),
+		after:  max(after, 0),
+		within: max(within, 0),
+	}
+}
+
+// SetOutputFormat selects how merged entries are written, unless a sink has been given with
+// [Processor.SetSink]. See [OutputFormats]. [OUTPUT_HTML] is rejected in follow mode, since the
+// report is only finished once every log has ended.
+func (this *Processor) SetOutputFormat(format string) error {
+	if format == OUTPUT_HTML && this.follow {
+		return fmt.Errorf("%s output cannot be used when following logs", OUTPUT_HTML)
+	}
+	switch format {
+	case OUTPUT_TEXT, OUTPUT_JSON, OUTPUT_CSV, OUTPUT_TSV, OUTPUT_HTML:
+		this.outputFormat = format
+		return nil
+	}
+	return fmt.Errorf("unknown output format '%s'; expected one of %s", format, strings.Join(OutputFormats, ", "))
+}
+
+// SetColumns chooses the columns of CSV and TSV output. See [ColumnNames].
+func (this *Processor) SetColumns(columns []string) error {
+	if err := checkColumns(columns); err != nil {
+		return err
+	}
+	this.columns = columns
+	return nil
+}
+
+// SetSink renders merged entries with the given sink in place of the one for the output format.
+// See [Processor.SetOutputFormat].
+func (this *Processor) SetSink(sink Sink) {
+	this.sink = sink
+}
+
+// SetCausalTies enables breaking ties between entries with identical timestamps using
+// membership view IDs: an entry from a member which has already seen a later view is placed
+// after one from a member which has not. Applies to subsequently added logs.
+func (this *Processor) SetCausalTies(enabled bool) {
+	this.causalTies = enabled
+}
+
+// SetTimestampLayout forces every subsequently added log to use the given timestamp layout
+// instead of detecting it. A nil layout restores detection.
+func (this *Processor) SetTimestampLayout(layout *TimestampLayout) {
+	this.timestampLayout = layout
+}
+
 func (this *Processor) SetPalette(palette []ColorFn) {
 	this.palette = palette
 }
@@ -143,3 +404,30 @@ func (this *Processor) SetPalette(palette []ColorFn) {
 func (this *Processor) SetWriter(writer io.Writer) {
 	this.writer = writer
 }
+
+// logHeap is a min-heap of logs ordered by their next line. See [LogFile.before].
+type logHeap []*LogFile
+
+func (h logHeap) Len() int {
+	return len(h)
+}
+
+func (h logHeap) Less(i, j int) bool {
+	return h[i].before(h[j])
+}
+
+func (h logHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *logHeap) Push(x any) {
+	*h = append(*h, x.(*LogFile))
+}
+
+func (h *logHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
@@ -0,0 +1,58 @@
+package mergedlog_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"merge-logs/mergedlog"
+	"strings"
+	"testing"
+	"time"
+)
+
+const benchEntriesPerLog = 100
+
+// makeBenchLogs creates logs whose entries interleave, so that every log is repeatedly the
+// source of the next entry.
+func makeBenchLogs(count int) []string {
+	start := time.Date(2018, 1, 25, 19, 9, 36, 0, time.UTC)
+	logs := make([]string, count)
+	for i := range logs {
+		builder := strings.Builder{}
+		for j := 0; j < benchEntriesPerLog; j++ {
+			stamp := start.Add(time.Duration(j*count+i) * time.Millisecond)
+			fmt.Fprintf(&builder, "[info %s server%d <main> tid=0x1] entry %d\n",
+				stamp.Format(mergedlog.STAMP_FORMAT), i, j)
+		}
+		logs[i] = builder.String()
+	}
+	return logs
+}
+
+func benchmarkCrank(b *testing.B, count int) {
+	logs := makeBenchLogs(count)
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		processor := mergedlog.NewProcessor(0, mergedlog.MAX_INT, nil, nil, 0)
+		processor.SetPalette(noopPalette)
+		processor.SetWriter(bufio.NewWriter(io.Discard))
+		for i, l := range logs {
+			processor.AddLog(fmt.Sprintf("server%d", i), false, strings.NewReader(l), bufio.MaxScanTokenSize)
+		}
+		processor.SetFormat(10)
+		processor.Crank()
+	}
+}
+
+func BenchmarkCrank10(b *testing.B) {
+	benchmarkCrank(b, 10)
+}
+
+func BenchmarkCrank100(b *testing.B) {
+	benchmarkCrank(b, 100)
+}
+
+func BenchmarkCrank1000(b *testing.B) {
+	benchmarkCrank(b, 1000)
+}
@@ -7,6 +7,7 @@ import (
 	"merge-logs/mergedlog"
 	"regexp"
 	"strings"
+	"time"
 )
 
 // regex is nil
@@ -16,7 +17,7 @@ var noopPalette []mergedlog.ColorFn
 func init() {
 	noopPalette = make([]mergedlog.ColorFn, 1)
 	f := func(s string) mergedlog.Highlighted { return mergedlog.Highlighted(s) }
-	noopPalette[0] = mergedlog.ColorFn{f, f, f}
+	noopPalette[0] = mergedlog.ColorFn{Normal: f, Grep: f, Highlight: f}
 }
 
 var _ = Describe("processor integration test", func() {
@@ -177,7 +178,7 @@ AnotherException
 			testPalette := make([]mergedlog.ColorFn, 1)
 			f1 := func(s string) mergedlog.Highlighted { return mergedlog.Highlighted(s) }
 			f2 := func(s string) mergedlog.Highlighted { return mergedlog.Highlighted("#" + s + "#") }
-			testPalette[0] = mergedlog.ColorFn{f1, f2, f1}
+			testPalette[0] = mergedlog.ColorFn{Normal: f1, Grep: f2, Highlight: f1}
 
 			processor := mergedlog.NewProcessor(0, mergedlog.MAX_INT, regex, nilRegex, 0)
 			result := &strings.Builder{}
@@ -209,7 +210,7 @@ SomeException
 			f1 := func(s string) mergedlog.Highlighted { return mergedlog.Highlighted(">" + s + "<") }
 			f2 := func(s string) mergedlog.Highlighted { return mergedlog.Highlighted("#" + s + "#") }
 			f3 := func(s string) mergedlog.Highlighted { return mergedlog.Highlighted("%" + s + "%") }
-			testPalette[0] = mergedlog.ColorFn{f1, f2, f3}
+			testPalette[0] = mergedlog.ColorFn{Normal: f1, Grep: f2, Highlight: f3}
 
 			processor := mergedlog.NewProcessor(0, mergedlog.MAX_INT, regex1, regex2, 0)
 			result := &strings.Builder{}
@@ -239,7 +240,7 @@ SomeException line1
 			testPalette := make([]mergedlog.ColorFn, 1)
 			f1 := func(s string) mergedlog.Highlighted { return mergedlog.Highlighted(s) }
 			f2 := func(s string) mergedlog.Highlighted { return mergedlog.Highlighted("#" + s + "#") }
-			testPalette[0] = mergedlog.ColorFn{f1, f2, f1}
+			testPalette[0] = mergedlog.ColorFn{Normal: f1, Grep: f2, Highlight: f1}
 
 			processor := mergedlog.NewProcessor(0, mergedlog.MAX_INT, regex, nilRegex, 0)
 			result := &strings.Builder{}
@@ -281,7 +282,7 @@ SomeException
 			testPalette := make([]mergedlog.ColorFn, 1)
 			f1 := func(s string) mergedlog.Highlighted { return mergedlog.Highlighted(">" + s + "<") }
 			f2 := func(s string) mergedlog.Highlighted { return mergedlog.Highlighted("#" + s + "#") }
-			testPalette[0] = mergedlog.ColorFn{f1, f1, f2}
+			testPalette[0] = mergedlog.ColorFn{Normal: f1, Grep: f1, Highlight: f2}
 
 			processor := mergedlog.NewProcessor(0, mergedlog.MAX_INT, nilRegex, regex, 0)
 			result := &strings.Builder{}
@@ -305,4 +306,562 @@ SomeException and again SomeException
 			}))
 		})
 	})
+
+	Context("when entries have identical timestamps", func() {
+		It("orders them by file and then by original line order", func() {
+			file1 := `[info 2015/11/19 08:52:39.504 PST  file1 line1
+[info 2015/11/19 08:52:39.504 PST  file1 line2`
+			file2 := `[info 2015/11/19 08:52:39.503 PST  file2 line1
+[info 2015/11/19 08:52:39.504 PST  file2 line2
+[info 2015/11/19 08:52:39.504 PST  file2 line3`
+			file3 := `[info 2015/11/19 08:52:39.504 PST  file3 line1`
+
+			processor.AddLog("", false, strings.NewReader(file1), bufio.MaxScanTokenSize)
+			processor.AddLog("", false, strings.NewReader(file2), bufio.MaxScanTokenSize)
+			processor.AddLog("", false, strings.NewReader(file3), bufio.MaxScanTokenSize)
+			processor.SetFormat(0)
+			processor.Crank()
+
+			Expect(strings.Split(strings.TrimSpace(result.String()), "\n")).To(Equal([]string{
+				"[] [info 2015/11/19 08:52:39.503 PST  file2 line1",
+				"[] [info 2015/11/19 08:52:39.504 PST  file1 line1",
+				"[] [info 2015/11/19 08:52:39.504 PST  file1 line2",
+				"[] [info 2015/11/19 08:52:39.504 PST  file2 line2",
+				"[] [info 2015/11/19 08:52:39.504 PST  file2 line3",
+				"[] [info 2015/11/19 08:52:39.504 PST  file3 line1",
+			}))
+		})
+
+		It("gives the same order on every run", func() {
+			file1 := `[info 2015/11/19 08:52:39.504 PST  a1
+[info 2015/11/19 08:52:39.504 PST  a2`
+			file2 := `[info 2015/11/19 08:52:39.504 PST  b1
+[info 2015/11/19 08:52:39.504 PST  b2`
+
+			var first string
+			for i := 0; i < 20; i++ {
+				processor := mergedlog.NewProcessor(0, mergedlog.MAX_INT, nilRegex, nilRegex, 0)
+				processor.SetPalette(noopPalette)
+				result := &strings.Builder{}
+				processor.SetWriter(result)
+				processor.AddLog("a", false, strings.NewReader(file1), bufio.MaxScanTokenSize)
+				processor.AddLog("b", false, strings.NewReader(file2), bufio.MaxScanTokenSize)
+				processor.SetFormat(1)
+				processor.Crank()
+
+				if i == 0 {
+					first = result.String()
+				}
+				Expect(result.String()).To(Equal(first))
+			}
+		})
+
+		It("orders them by membership view when breaking ties causally", func() {
+			processor.SetCausalTies(true)
+
+			// The server, although listed first, has already seen view 2 so the locator's entries
+			// from view 1, including sending view 2, must come first
+			server := `[info 2015/11/19 08:52:39.504 PST  server received new membership view: View[locator(locator:1)<v0>:1024|2] members: [...]
+[info 2015/11/19 08:52:39.504 PST  server after view 2`
+			locator := `[info 2015/11/19 08:52:39.503 PST  locator preparing View[locator(locator:1)<v0>:1024|1]
+[info 2015/11/19 08:52:39.504 PST  locator still in view 1
+[info 2015/11/19 08:52:39.504 PST  locator sending View[locator(locator:1)<v0>:1024|2]`
+
+			processor.AddLog("", false, strings.NewReader(server), bufio.MaxScanTokenSize)
+			processor.AddLog("", false, strings.NewReader(locator), bufio.MaxScanTokenSize)
+			processor.SetFormat(0)
+			processor.Crank()
+
+			Expect(strings.Split(strings.TrimSpace(result.String()), "\n")).To(Equal([]string{
+				"[] [info 2015/11/19 08:52:39.503 PST  locator preparing View[locator(locator:1)<v0>:1024|1]",
+				"[] [info 2015/11/19 08:52:39.504 PST  locator still in view 1",
+				"[] [info 2015/11/19 08:52:39.504 PST  locator sending View[locator(locator:1)<v0>:1024|2]",
+				"[] [info 2015/11/19 08:52:39.504 PST  server received new membership view: View[locator(locator:1)<v0>:1024|2] members: [...]",
+				"[] [info 2015/11/19 08:52:39.504 PST  server after view 2",
+			}))
+		})
+	})
+
+	Context("when correcting clock skew", func() {
+		file1 := `[info 2015/11/19 08:52:39.504 PST  a1
+
+[info 2015/11/19 08:52:39.700 PST  a2`
+		file2 := `[info 2015/11/19 08:52:39.600 PST  b1`
+
+		It("orders entries by the adjusted timestamp", func() {
+			processor.SetOffset("a", 200*time.Millisecond)
+			processor.AddLog("a", false, strings.NewReader(file1), bufio.MaxScanTokenSize)
+			processor.AddLog("b", false, strings.NewReader(file2), bufio.MaxScanTokenSize)
+			processor.SetFormat(1)
+			processor.Crank()
+
+			Expect(strings.Split(strings.TrimSpace(result.String()), "\n")).To(Equal([]string{
+				"[b] [info 2015/11/19 08:52:39.600 PST  b1",
+				"[a] [info 2015/11/19 08:52:39.504 PST  a1",
+				"[a] ",
+				"[a] [info 2015/11/19 08:52:39.700 PST  a2",
+			}))
+		})
+
+		It("limits the range using the adjusted timestamp", func() {
+			processor := mergedlog.NewProcessor(1447951959650000000, mergedlog.MAX_INT, nilRegex, nilRegex, 0)
+			processor.SetPalette(noopPalette)
+			result := &strings.Builder{}
+			processor.SetWriter(result)
+			processor.SetOffset("a", 200*time.Millisecond)
+			processor.AddLog("a", false, strings.NewReader(file1), bufio.MaxScanTokenSize)
+			processor.AddLog("b", false, strings.NewReader(file2), bufio.MaxScanTokenSize)
+			processor.SetFormat(1)
+			processor.Crank()
+
+			Expect(strings.Split(strings.TrimSpace(result.String()), "\n")).To(Equal([]string{
+				"[a] [info 2015/11/19 08:52:39.504 PST  a1",
+				"[a] ",
+				"[a] [info 2015/11/19 08:52:39.700 PST  a2",
+			}))
+		})
+
+		It("shows the adjusted timestamp", func() {
+			processor.SetOffset("a", -4*time.Millisecond)
+			processor.SetShowAdjusted(true)
+			processor.AddLog("a", false, strings.NewReader(file1), bufio.MaxScanTokenSize)
+			processor.AddLog("b", false, strings.NewReader(file2), bufio.MaxScanTokenSize)
+			processor.SetFormat(1)
+			processor.Crank()
+
+			Expect(strings.Split(strings.TrimSpace(result.String()), "\n")).To(Equal([]string{
+				"[a] (-4ms: 2015/11/19 08:52:39.500 PST) [info 2015/11/19 08:52:39.504 PST  a1",
+				"[a] ",
+				"[b] [info 2015/11/19 08:52:39.600 PST  b1",
+				"[a] (-4ms: 2015/11/19 08:52:39.696 PST) [info 2015/11/19 08:52:39.700 PST  a2",
+			}))
+		})
+
+		It("shows the adjusted timestamp in the layout of the log", func() {
+			processor.SetOffset("b", 250*time.Millisecond)
+			processor.SetShowAdjusted(true)
+			processor.AddFormattedLog(mergedlog.ISO8601Format, "b", false, strings.NewReader("2015-11-19 08:52:39.600 UTC INFO b1"), bufio.MaxScanTokenSize)
+			processor.SetFormat(1)
+			processor.Crank()
+
+			Expect(strings.TrimSpace(result.String())).To(Equal(
+				"[b] (+250ms: 2015-11-19 08:52:39.850 UTC) 2015-11-19 08:52:39.600 UTC INFO b1"))
+		})
+
+		It("rejects offsets for tags which no log has", func() {
+			processor.SetOffset("a", time.Second)
+			processor.SetOffset("server1", time.Second)
+			processor.AddLog("a", false, strings.NewReader(file1), bufio.MaxScanTokenSize)
+			processor.AddLog("b", true, strings.NewReader(file2), bufio.MaxScanTokenSize)
+
+			Expect(processor.CheckOffsets()).To(MatchError("no log has the tag server1; expected one of a, b"))
+		})
+	})
+
+	Context("when converting time zones", func() {
+		It("rewrites entry timestamps in the chosen zone", func() {
+			processor.SetLocation(time.UTC)
+			processor.AddLog("a", false, strings.NewReader("[info 2015/11/19 08:52:39.504 PST  a1"), bufio.MaxScanTokenSize)
+			processor.AddLog("b", false, strings.NewReader("[info 2015/11/19 11:52:39.600 EST  b1"), bufio.MaxScanTokenSize)
+			processor.SetFormat(1)
+			processor.Crank()
+
+			Expect(strings.Split(strings.TrimSpace(result.String()), "\n")).To(Equal([]string{
+				"[a] [info 2015/11/19 16:52:39.504 UTC  a1",
+				"[b] [info 2015/11/19 16:52:39.600 UTC  b1",
+			}))
+		})
+
+		It("keeps the precision and writes the zone of local timestamps", func() {
+			processor.SetLocation(time.UTC)
+			processor.AddFormattedLog(mergedlog.ISO8601Format, "a", false, strings.NewReader("2015-11-19 08:52:39,504 INFO a1"), bufio.MaxScanTokenSize)
+			processor.SetFormat(1)
+			processor.Crank()
+
+			logged := time.Date(2015, 11, 19, 8, 52, 39, 504000000, time.Local)
+			Expect(strings.TrimSpace(result.String())).To(Equal(
+				"[a] " + logged.UTC().Format("2006-01-02 15:04:05.000") + " UTC INFO a1"))
+		})
+	})
+
+	Context("when filtering by level", func() {
+		It("returns only entries of the selected levels", func() {
+			filter, err := mergedlog.ParseLevelFilter("warning+")
+			Expect(err).ShouldNot(HaveOccurred())
+			processor.SetLevelFilter(filter)
+			processor.AddLog("a", false, strings.NewReader(`[fine 2015/11/19 08:52:39.504 PST  a1
+
+[warning 2015/11/19 08:52:39.700 PST  a2`), bufio.MaxScanTokenSize)
+			processor.AddFormattedLog(mergedlog.ISO8601Format, "b", false, strings.NewReader(`2015-11-19 08:52:39.600 PST ERROR b1
+2015-11-19 08:52:39.650 PST DEBUG b2`), bufio.MaxScanTokenSize)
+			processor.SetFormat(1)
+			processor.Crank()
+
+			Expect(strings.Split(strings.TrimSpace(result.String()), "\n")).To(Equal([]string{
+				"[b] 2015-11-19 08:52:39.600 PST ERROR b1",
+				"[a] [warning 2015/11/19 08:52:39.700 PST  a2",
+			}))
+		})
+	})
+
+	Context("when filtering by thread", func() {
+		file1 := `[info 2015/11/19 08:52:39.504 PST a <main> tid=0x1] starting
+[warning 2015/11/19 08:52:39.700 PST a <P2P message reader for b> tid=0x4f] slow receiver`
+		file2 := `[info 2015/11/19 08:52:39.600 PST b <P2P message reader for a> tid=0x50] connected`
+
+		It("returns only entries from the selected threads", func() {
+			filter, err := mergedlog.NewThreadFilter("^P2P", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			processor.SetThreadFilter(filter)
+			processor.AddLog("a", false, strings.NewReader(file1), bufio.MaxScanTokenSize)
+			processor.AddLog("b", false, strings.NewReader(file2), bufio.MaxScanTokenSize)
+			processor.SetFormat(1)
+			processor.Crank()
+
+			Expect(strings.Split(strings.TrimSpace(result.String()), "\n")).To(Equal([]string{
+				"[b] [info 2015/11/19 08:52:39.600 PST b <P2P message reader for a> tid=0x50] connected",
+				"[a] [warning 2015/11/19 08:52:39.700 PST a <P2P message reader for b> tid=0x4f] slow receiver",
+			}))
+		})
+
+		It("combines with grep", func() {
+			processor := mergedlog.NewProcessor(0, mergedlog.MAX_INT, mergedlog.MakeGrepRegex("slow|starting"), nilRegex, 0)
+			processor.SetPalette(noopPalette)
+			result := &strings.Builder{}
+			processor.SetWriter(result)
+			filter, err := mergedlog.NewThreadFilter("", []string{"0x4f", "0x50"})
+			Expect(err).ShouldNot(HaveOccurred())
+			processor.SetThreadFilter(filter)
+			processor.AddLog("a", false, strings.NewReader(file1), bufio.MaxScanTokenSize)
+			processor.AddLog("b", false, strings.NewReader(file2), bufio.MaxScanTokenSize)
+			processor.SetFormat(1)
+			processor.Crank()
+
+			Expect(strings.Split(strings.TrimSpace(result.String()), "\n")).To(Equal([]string{
+				"[a] [warning 2015/11/19 08:52:39.700 PST a <P2P message reader for b> tid=0x4f] slow receiver",
+			}))
+		})
+	})
+
+	Context("when grepping for several expressions", func() {
+		It("marks every matching expression and leaves out excluded entries", func() {
+			greps := []string{"view", "started"}
+			filter, err := mergedlog.NewGrepFilter(greps, []string{"heartbeat"}, false)
+			Expect(err).ShouldNot(HaveOccurred())
+			testPalette := make([]mergedlog.ColorFn, 1)
+			f1 := func(s string) mergedlog.Highlighted { return mergedlog.Highlighted(s) }
+			f2 := func(s string) mergedlog.Highlighted { return mergedlog.Highlighted("#" + s + "#") }
+			testPalette[0] = mergedlog.ColorFn{Normal: f1, Grep: f2, Highlight: f1}
+
+			processor := mergedlog.NewProcessor(0, mergedlog.MAX_INT, mergedlog.MakeMultiGrepRegex(greps), nilRegex, 0)
+			result := &strings.Builder{}
+			processor.SetWriter(result)
+			processor.SetPalette(testPalette)
+			processor.SetGrepFilter(filter)
+
+			file1 := `[info 2015/11/19 08:52:39.504 PST  received new view
+[fine 2015/11/19 08:52:39.600 PST  sending heartbeat with view 2
+[info 2015/11/19 08:52:39.700 PST  cache server started
+[info 2015/11/19 08:52:39.800 PST  something else`
+			processor.AddLog("a", false, strings.NewReader(file1), bufio.MaxScanTokenSize)
+			processor.SetFormat(1)
+			processor.Crank()
+
+			Expect(strings.Split(strings.TrimSpace(result.String()), "\n")).To(Equal([]string{
+				"[a] [info 2015/11/19 08:52:39.504 PST  received new #view#",
+				"[a] [info 2015/11/19 08:52:39.700 PST  cache server #started#",
+			}))
+		})
+	})
+
+	Context("when showing context", func() {
+		file1 := `[info 2015/11/19 08:52:39.100 PST  a1
+[info 2015/11/19 08:52:39.300 PST  a2
+[info 2015/11/19 08:52:39.500 PST  a3 ForcedDisconnectException
+[info 2015/11/19 08:52:39.700 PST  a4
+[info 2015/11/19 08:52:40.900 PST  a5 ForcedDisconnectException`
+		file2 := `[info 2015/11/19 08:52:39.200 PST  b1
+[info 2015/11/19 08:52:39.400 PST  b2
+[info 2015/11/19 08:52:39.600 PST  b3
+[info 2015/11/19 08:52:39.800 PST  b4
+[info 2015/11/19 08:52:40.800 PST  b5`
+
+		crank := func(setup func(*mergedlog.Processor)) []string {
+			processor := mergedlog.NewProcessor(0, mergedlog.MAX_INT, mergedlog.MakeGrepRegex("ForcedDisconnect"), nilRegex, 0)
+			processor.SetPalette(noopPalette)
+			result := &strings.Builder{}
+			processor.SetWriter(result)
+			setup(processor)
+			processor.AddLog("a", false, strings.NewReader(file1), bufio.MaxScanTokenSize)
+			processor.AddLog("b", false, strings.NewReader(file2), bufio.MaxScanTokenSize)
+			processor.SetFormat(1)
+			processor.Crank()
+			return strings.Split(strings.TrimSpace(result.String()), "\n")
+		}
+
+		It("shows entries from every log before and after each match", func() {
+			Expect(crank(func(p *mergedlog.Processor) { p.SetContext(2, 1, 0) })).To(Equal([]string{
+				"[a] [info 2015/11/19 08:52:39.300 PST  a2",
+				"[b] [info 2015/11/19 08:52:39.400 PST  b2",
+				"[a] [info 2015/11/19 08:52:39.500 PST  a3 ForcedDisconnectException",
+				"[b] [info 2015/11/19 08:52:39.600 PST  b3",
+				"--",
+				"[b] [info 2015/11/19 08:52:39.800 PST  b4",
+				"[b] [info 2015/11/19 08:52:40.800 PST  b5",
+				"[a] [info 2015/11/19 08:52:40.900 PST  a5 ForcedDisconnectException",
+			}))
+		})
+
+		It("does not separate contiguous groups", func() {
+			Expect(crank(func(p *mergedlog.Processor) { p.SetContext(0, 4, 0) })).To(Equal([]string{
+				"[a] [info 2015/11/19 08:52:39.500 PST  a3 ForcedDisconnectException",
+				"[b] [info 2015/11/19 08:52:39.600 PST  b3",
+				"[a] [info 2015/11/19 08:52:39.700 PST  a4",
+				"[b] [info 2015/11/19 08:52:39.800 PST  b4",
+				"[b] [info 2015/11/19 08:52:40.800 PST  b5",
+				"[a] [info 2015/11/19 08:52:40.900 PST  a5 ForcedDisconnectException",
+			}))
+		})
+
+		It("shows entries within a time of each match", func() {
+			Expect(crank(func(p *mergedlog.Processor) { p.SetContext(0, 0, 150*time.Millisecond) })).To(Equal([]string{
+				"[b] [info 2015/11/19 08:52:39.400 PST  b2",
+				"[a] [info 2015/11/19 08:52:39.500 PST  a3 ForcedDisconnectException",
+				"[b] [info 2015/11/19 08:52:39.600 PST  b3",
+				"--",
+				"[b] [info 2015/11/19 08:52:40.800 PST  b5",
+				"[a] [info 2015/11/19 08:52:40.900 PST  a5 ForcedDisconnectException",
+			}))
+		})
+	})
+
+	Context("when writing JSON", func() {
+		It("writes one object per entry", func() {
+			Expect(processor.SetOutputFormat(mergedlog.OUTPUT_JSON)).To(Succeed())
+			processor.SetOffset("b", time.Second)
+			processor.AddNamedLog(mergedlog.GemFireFormat, "logs/a.log", "a", false, strings.NewReader(`[info 2015/11/19 08:52:39.504 PST a <main> tid=0x1] starting
+
+[warning 2015/11/19 08:52:41.000 PST a <main> tid=0x1] "slow" <receiver>
+	at Foo.bar(Foo.java:1)`), bufio.MaxScanTokenSize)
+			processor.AddNamedLog(mergedlog.GemFireFormat, "logs/b.log", "b", true, strings.NewReader(`[info 2015/11/19 08:52:39.600 PST b <main> tid=0x1] started`), bufio.MaxScanTokenSize)
+			processor.SetFormat(1)
+			processor.Crank()
+
+			lines := strings.Split(strings.TrimSpace(result.String()), "\n")
+			Expect(lines).To(HaveLen(3))
+			Expect(lines[0]).To(MatchJSON(`{"alias":"a","file":"logs/a.log","line":1,"epochNanos":1447951959504000000,
+				"timestamp":"2015-11-19T08:52:39.504-08:00","level":"info","member":"a","thread":"main","tid":"0x1",
+				"message":"starting\n","text":"[info 2015/11/19 08:52:39.504 PST a <main> tid=0x1] starting\n"}`))
+			Expect(lines[1]).To(MatchJSON(`{"alias":"b","file":"logs/b.log","line":1,"epochNanos":1447951960600000000,
+				"timestamp":"2015-11-19T08:52:40.6-08:00","level":"info","member":"b","thread":"main","tid":"0x1",
+				"message":"started","text":"[info 2015/11/19 08:52:39.600 PST b <main> tid=0x1] started"}`))
+			Expect(lines[2]).To(MatchJSON(`{"alias":"a","file":"logs/a.log","line":3,"epochNanos":1447951961000000000,
+				"timestamp":"2015-11-19T08:52:41-08:00","level":"warning","member":"a","thread":"main","tid":"0x1",
+				"message":"\"slow\" <receiver>\n\tat Foo.bar(Foo.java:1)",
+				"text":"[warning 2015/11/19 08:52:41.000 PST a <main> tid=0x1] \"slow\" <receiver>\n\tat Foo.bar(Foo.java:1)"}`))
+		})
+
+		It("rejects unknown output formats", func() {
+			Expect(processor.SetOutputFormat("yaml")).ToNot(Succeed())
+		})
+	})
+
+	Context("when writing CSV or TSV", func() {
+		file1 := `[info 2015/11/19 08:52:39.504 PST a <main> tid=0x1] starting
+[warning 2015/11/19 08:52:41.000 PST a <main> tid=0x1] "slow", receiver
+	at Foo.bar(Foo.java:1)`
+
+		It("writes a header and a quoted row per entry", func() {
+			Expect(processor.SetOutputFormat(mergedlog.OUTPUT_CSV)).To(Succeed())
+			processor.AddLog("a", false, strings.NewReader(file1), bufio.MaxScanTokenSize)
+			processor.SetFormat(1)
+			processor.Crank()
+
+			Expect(result.String()).To(Equal(`time,alias,level,member,thread,tid,message
+2015-11-19 08:52:39.504,a,info,a,main,0x1,starting
+2015-11-19 08:52:41.000,a,warning,a,main,0x1,"""slow"", receiver
+	at Foo.bar(Foo.java:1)"
+`))
+		})
+
+		It("writes the chosen columns separated by tabs", func() {
+			Expect(processor.SetOutputFormat(mergedlog.OUTPUT_TSV)).To(Succeed())
+			Expect(processor.SetColumns([]string{"epoch", "line", "level"})).To(Succeed())
+			processor.AddLog("a", false, strings.NewReader(file1), bufio.MaxScanTokenSize)
+			processor.SetFormat(1)
+			processor.Crank()
+
+			Expect(result.String()).To(Equal("epoch\tline\tlevel\n" +
+				"1447951959504000000\t1\tinfo\n" +
+				"1447951961000000000\t2\twarning\n"))
+		})
+
+		It("rejects unknown columns", func() {
+			Expect(processor.SetColumns([]string{"time", "colour"})).ToNot(Succeed())
+		})
+
+		It("accepts every column it lists", func() {
+			Expect(mergedlog.ColumnNames()).To(HaveLen(13))
+			Expect(mergedlog.ColumnNames()[0]).To(Equal("time"))
+			Expect(processor.SetColumns(mergedlog.ColumnNames())).To(Succeed())
+		})
+	})
+
+	Context("when writing HTML", func() {
+		It("writes a self-contained report in the colors of the palette", func() {
+			regex := regexp.MustCompile("(.*)(SomeException)(.*)")
+			processor := mergedlog.NewProcessor(0, mergedlog.MAX_INT, nilRegex, regex, 0)
+			result := &strings.Builder{}
+			processor.SetWriter(result)
+			processor.SetPalette([]mergedlog.ColorFn{mergedlog.MakePaletteEntry("64")})
+			Expect(processor.SetOutputFormat(mergedlog.OUTPUT_HTML)).To(Succeed())
+
+			file1 := `[info 2015/11/19 08:52:39.504 PST  a <b> & c
+[error 2015/11/19 08:52:40.774 PST  failed
+SomeException
+	at Foo.bar(Foo.java:1)`
+
+			processor.AddLog("a", false, strings.NewReader(file1), bufio.MaxScanTokenSize)
+			processor.SetFormat(1)
+			processor.Crank()
+
+			report := result.String()
+			Expect(report).To(HavePrefix("<!DOCTYPE html>"))
+			Expect(report).To(HaveSuffix("</html>\n"))
+			Expect(report).ToNot(ContainSubstring("\x1b"))
+			Expect(report).ToNot(MatchRegexp(`(?i)<(script|link)[^>]+(src|href)=`))
+			Expect(report).To(ContainSubstring(`<div class="e" data-alias="a">`))
+			Expect(report).To(ContainSubstring("a &lt;b&gt; &amp; c"))
+			Expect(report).To(ContainSubstring(`<span style="color:#5f8700">`))
+			Expect(report).To(ContainSubstring(
+				`<span style="color:var(--bg);background:#5f8700">SomeException</span>`))
+			Expect(report).To(MatchRegexp(`<details><summary>.*failed</span></summary>.*SomeException`))
+		})
+
+		It("styles entries from named colors and leaves uncolored entries plain", func() {
+			regex := mergedlog.MakeGrepRegex("SomeException")
+			processor := mergedlog.NewProcessor(0, mergedlog.MAX_INT, regex, nilRegex, 0)
+			result := &strings.Builder{}
+			processor.SetWriter(result)
+			plain := func(s string) mergedlog.Highlighted { return mergedlog.Highlighted(s) }
+			processor.SetPalette([]mergedlog.ColorFn{
+				mergedlog.MakePaletteEntry("red+b"),
+				{Normal: plain, Grep: plain, Highlight: plain},
+			})
+			Expect(processor.SetOutputFormat(mergedlog.OUTPUT_HTML)).To(Succeed())
+
+			file1 := "[info 2015/11/19 08:52:39.504 PST  a SomeException"
+			file2 := "[info 2015/11/19 08:52:40.774 PST  b SomeException"
+
+			processor.AddLog("a", false, strings.NewReader(file1), bufio.MaxScanTokenSize)
+			processor.AddLog("b", false, strings.NewReader(file2), bufio.MaxScanTokenSize)
+			processor.SetFormat(1)
+			processor.Crank()
+
+			report := result.String()
+			Expect(report).To(ContainSubstring(`<span class="alias"><span style="color:#800000">a</span></span>`))
+			Expect(report).To(ContainSubstring(
+				`<span style="color:var(--bg);background:#800000">SomeException</span>`))
+			Expect(report).To(ContainSubstring(`<span class="alias">b</span> [info 2015/11/19 08:52:40.774 PST  b ` +
+				`<span style="color:var(--bg);background:var(--fg)">SomeException</span>`))
+		})
+
+		It("toggles rolled logs with the rest of their alias", func() {
+			processor := mergedlog.NewProcessor(0, mergedlog.MAX_INT, nilRegex, nilRegex, 0)
+			result := &strings.Builder{}
+			processor.SetWriter(result)
+			Expect(processor.SetOutputFormat(mergedlog.OUTPUT_HTML)).To(Succeed())
+
+			rolled := "[info 2015/11/19 08:52:39.504 PST  before the roll"
+			current := "[info 2015/11/19 08:52:40.774 PST  after the roll"
+
+			processor.AddLog("a", true, strings.NewReader(rolled), bufio.MaxScanTokenSize)
+			processor.AddLog("a", false, strings.NewReader(current), bufio.MaxScanTokenSize)
+			processor.SetFormat(1)
+			processor.Crank()
+
+			report := result.String()
+			Expect(strings.Count(report, `data-alias="a"`)).To(Equal(2))
+			Expect(report).ToNot(ContainSubstring(`data-alias="a*"`))
+		})
+
+		It("cannot be used when following logs", func() {
+			processor := mergedlog.NewProcessor(0, mergedlog.MAX_INT, nilRegex, nilRegex, 0)
+			processor.SetFollow(time.Second)
+			Expect(processor.SetOutputFormat(mergedlog.OUTPUT_HTML)).ToNot(Succeed())
+			Expect(processor.SetOutputFormat(mergedlog.OUTPUT_JSON)).To(Succeed())
+		})
+	})
+
+	Context("when rendering with a sink", func() {
+		It("passes each merged entry to the sink with its spans classified", func() {
+			grep := mergedlog.MakeGrepRegex("SomeException")
+			highlight := regexp.MustCompile("(.*)(foo)(.*)")
+			processor := mergedlog.NewProcessor(0, mergedlog.MAX_INT, grep, highlight, 0)
+			sink := &recordingSink{}
+			processor.SetSink(sink)
+
+			file1 := `[info 2015/11/19 08:52:39.504 PST  a SomeException
+	at foo.com`
+			file2 := `[info 2015/11/19 08:52:39.505 PST  b SomeException`
+
+			processor.AddLog("a", false, strings.NewReader(file1), bufio.MaxScanTokenSize)
+			processor.AddLog("b", false, strings.NewReader(file2), bufio.MaxScanTokenSize)
+			processor.SetFormat(1)
+			processor.Crank()
+
+			Expect(sink.calls).To(Equal([]string{"start", "entry a", "entry b", "end", "flush"}))
+			Expect(sink.lines[0].Text).To(Equal(mergedlog.LogEntry{
+				{"[info 2015/11/19 08:52:39.504 PST  a ", mergedlog.GrepMatch("SomeException"), ""},
+				{"\tat ", mergedlog.HighlightMatch("foo"), ".com"},
+			}))
+			Expect(sink.lines[1].Text).To(Equal(mergedlog.LogEntry{
+				{"[info 2015/11/19 08:52:39.505 PST  b ", mergedlog.GrepMatch("SomeException"), ""},
+			}))
+		})
+
+		It("gives the sink the adjusted time of each entry", func() {
+			sink := &recordingSink{}
+			processor.SetSink(sink)
+			processor.SetOffset("b", -2*time.Second)
+			processor.SetLocation(time.UTC)
+
+			processor.AddLog("a", false, strings.NewReader("[info 2015/11/19 08:52:39.504 PST  a"), bufio.MaxScanTokenSize)
+			processor.AddLog("b", false, strings.NewReader("[info 2015/11/19 08:52:40.504 PST  b"), bufio.MaxScanTokenSize)
+			processor.SetFormat(1)
+			processor.Crank()
+
+			Expect(sink.calls).To(Equal([]string{"start", "entry b", "entry a", "end", "flush"}))
+			Expect(sink.lines[0].Offset).To(Equal(-2 * time.Second))
+			Expect(sink.lines[0].Adjusted).To(Equal(time.Date(2015, 11, 19, 16, 52, 38, 504000000, time.UTC)))
+			Expect(sink.lines[1].Offset).To(BeZero())
+			Expect(sink.lines[1].Adjusted).To(Equal(time.Date(2015, 11, 19, 16, 52, 39, 504000000, time.UTC)))
+		})
+
+		It("writes nothing with the no-op sink", func() {
+			processor.SetSink(mergedlog.NopSink{})
+			processor.AddLog("a", false, strings.NewReader("[info 2015/11/19 08:52:39.504 PST  a"), bufio.MaxScanTokenSize)
+			processor.SetFormat(1)
+			processor.Crank()
+
+			Expect(result.String()).To(BeEmpty())
+		})
+	})
 })
+
+// recordingSink records the calls made to it and the entries it is given
+type recordingSink struct {
+	calls []string
+	lines []*mergedlog.LogLine
+}
+
+func (s *recordingSink) Start() { s.calls = append(s.calls, "start") }
+
+func (s *recordingSink) Entry(line *mergedlog.LogLine) {
+	s.calls = append(s.calls, "entry "+line.Alias)
+	s.lines = append(s.lines, line)
+}
+
+func (s *recordingSink) Separate() { s.calls = append(s.calls, "separate") }
+func (s *recordingSink) Flush()    { s.calls = append(s.calls, "flush") }
+func (s *recordingSink) End()      { s.calls = append(s.calls, "end") }
@@ -0,0 +1,352 @@
+package mergedlog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Query is a compiled filter expression over the [Fields] of an entry, for example:
+//
+//	level >= warning and member ~ "server-[12]" and not msg ~ "heartbeat"
+//
+// A comparison is a field, an operator and a value. The fields are level, member, thread, tid,
+// msg (or message) and alias. The operators are = and != for equality, ~ and !~ for regular
+// expression matches and, for level only, <, <=, > and >=. Values are either quoted with double
+// or single quotes or are single words. Comparisons are combined with and, or, not and
+// parentheses.
+type Query struct {
+	text string
+	root queryNode
+}
+
+// QueryError describes why a query could not be parsed
+type QueryError struct {
+	Query string
+	// Pos is the byte offset in the query at which the error was found
+	Pos int
+	Msg string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("position %d of '%s': %s", e.Pos+1, e.Query, e.Msg)
+}
+
+// ParseQuery compiles a query. See [Query].
+func ParseQuery(text string) (*Query, error) {
+	tokens, err := lexQuery(text)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &queryParser{text: text, tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEnd {
+		return nil, p.errorf(tok, "unexpected '%s'", tok.text)
+	}
+
+	return &Query{text: text, root: root}, nil
+}
+
+// Matches reports whether an entry, from the log with the given alias, is selected
+func (q *Query) Matches(alias string, fields Fields) bool {
+	return q.root.matches(alias, &fields)
+}
+
+func (q *Query) String() string {
+	return q.text
+}
+
+type queryNode interface {
+	matches(alias string, fields *Fields) bool
+}
+
+type andNode struct{ left, right queryNode }
+type orNode struct{ left, right queryNode }
+type notNode struct{ operand queryNode }
+
+func (n *andNode) matches(alias string, fields *Fields) bool {
+	return n.left.matches(alias, fields) && n.right.matches(alias, fields)
+}
+
+func (n *orNode) matches(alias string, fields *Fields) bool {
+	return n.left.matches(alias, fields) || n.right.matches(alias, fields)
+}
+
+func (n *notNode) matches(alias string, fields *Fields) bool {
+	return !n.operand.matches(alias, fields)
+}
+
+// textNode compares a text field
+type textNode struct {
+	field  func(alias string, fields *Fields) string
+	negate bool
+	value  string
+	re     *regexp.Regexp
+}
+
+func (n *textNode) matches(alias string, fields *Fields) bool {
+	text := n.field(alias, fields)
+	if n.re != nil {
+		return n.re.MatchString(text) != n.negate
+	}
+	return (text == n.value) != n.negate
+}
+
+// tidNode compares thread IDs numerically, so that "0x4f" and "4F" are equal
+type tidNode struct {
+	tid    uint64
+	negate bool
+}
+
+func (n *tidNode) matches(alias string, fields *Fields) bool {
+	tid, err := parseTID(fields.TID)
+	return (err == nil && tid == n.tid) != n.negate
+}
+
+// levelNode compares levels by severity. Entries of unknown level never match.
+type levelNode struct {
+	op    string
+	level Level
+}
+
+func (n *levelNode) matches(alias string, fields *Fields) bool {
+	level := fields.Level
+	if level == LevelUnknown {
+		return false
+	}
+	switch n.op {
+	case "=":
+		return level == n.level
+	case "!=":
+		return level != n.level
+	case "<":
+		return level < n.level
+	case "<=":
+		return level <= n.level
+	case ">":
+		return level > n.level
+	default:
+		return level >= n.level
+	}
+}
+
+var queryTextFields = map[string]func(alias string, fields *Fields) string{
+	"alias":   func(alias string, fields *Fields) string { return alias },
+	"member":  func(alias string, fields *Fields) string { return fields.Member },
+	"thread":  func(alias string, fields *Fields) string { return fields.Thread },
+	"msg":     func(alias string, fields *Fields) string { return fields.Message },
+	"message": func(alias string, fields *Fields) string { return fields.Message },
+	"level":   func(alias string, fields *Fields) string { return fields.Level.String() },
+	"tid":     func(alias string, fields *Fields) string { return fields.TID },
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenWord
+	tokenString
+	tokenOp
+	tokenOpen
+	tokenClose
+)
+
+type queryToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var queryOperators = []string{"!=", "!~", "<=", ">=", "=", "~", "<", ">"}
+
+func lexQuery(text string) ([]queryToken, error) {
+	var tokens []queryToken
+	i := 0
+	for i < len(text) {
+		c := rune(text[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, queryToken{tokenOpen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, queryToken{tokenClose, ")", i})
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexRune(text[i+1:], c)
+			if end < 0 {
+				return nil, &QueryError{text, i, "unterminated string"}
+			}
+			tokens = append(tokens, queryToken{tokenString, text[i+1 : i+1+end], i})
+			i += end + 2
+		case strings.ContainsRune("!=~<>", c):
+			op := ""
+			for _, candidate := range queryOperators {
+				if strings.HasPrefix(text[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, &QueryError{text, i, fmt.Sprintf("unknown operator '%c'", c)}
+			}
+			tokens = append(tokens, queryToken{tokenOp, op, i})
+			i += len(op)
+		default:
+			start := i
+			for i < len(text) && !unicode.IsSpace(rune(text[i])) && !strings.ContainsRune("()\"'!=~<>", rune(text[i])) {
+				i++
+			}
+			tokens = append(tokens, queryToken{tokenWord, text[start:i], start})
+		}
+	}
+	return append(tokens, queryToken{tokenEnd, "end of query", len(text)}), nil
+}
+
+type queryParser struct {
+	text   string
+	tokens []queryToken
+	next   int
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.next]
+}
+
+func (p *queryParser) take() queryToken {
+	tok := p.tokens[p.next]
+	if tok.kind != tokenEnd {
+		p.next++
+	}
+	return tok
+}
+
+func (p *queryParser) errorf(tok queryToken, format string, args ...any) error {
+	return &QueryError{p.text, tok.pos, fmt.Sprintf(format, args...)}
+}
+
+func (p *queryParser) isKeyword(keyword string) bool {
+	tok := p.peek()
+	return tok.kind == tokenWord && strings.EqualFold(tok.text, keyword)
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("or") {
+		p.take()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("and") {
+		p.take()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseNot() (queryNode, error) {
+	if p.isKeyword("not") {
+		p.take()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *queryParser) parsePrimary() (queryNode, error) {
+	tok := p.take()
+	switch tok.kind {
+	case tokenOpen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.take(); closing.kind != tokenClose {
+			return nil, p.errorf(closing, "expected ')' but found '%s'", closing.text)
+		}
+		return node, nil
+	case tokenWord:
+		return p.parseComparison(tok)
+	default:
+		return nil, p.errorf(tok, "expected a field name but found '%s'", tok.text)
+	}
+}
+
+func (p *queryParser) parseComparison(field queryToken) (queryNode, error) {
+	name := strings.ToLower(field.text)
+	fieldFn, ok := queryTextFields[name]
+	if !ok {
+		return nil, p.errorf(field, "unknown field '%s'; expected one of alias, level, member, thread, tid or msg", field.text)
+	}
+
+	op := p.take()
+	if op.kind != tokenOp {
+		return nil, p.errorf(op, "expected an operator after '%s' but found '%s'", field.text, op.text)
+	}
+
+	value := p.take()
+	if value.kind != tokenWord && value.kind != tokenString {
+		return nil, p.errorf(value, "expected a value after '%s' but found '%s'", op.text, value.text)
+	}
+
+	switch op.text {
+	case "~", "!~":
+		re, err := regexp.Compile(value.text)
+		if err != nil {
+			return nil, p.errorf(value, "invalid regular expression: %s", err)
+		}
+		return &textNode{field: fieldFn, negate: op.text == "!~", re: re}, nil
+	}
+
+	switch name {
+	case "level":
+		level, err := ParseLevel(value.text)
+		if err != nil {
+			return nil, p.errorf(value, "%s", err)
+		}
+		return &levelNode{op: op.text, level: level}, nil
+	case "tid":
+		if op.text != "=" && op.text != "!=" {
+			break
+		}
+		tid, err := parseTID(value.text)
+		if err != nil {
+			return nil, p.errorf(value, "%s", err)
+		}
+		return &tidNode{tid: tid, negate: op.text == "!="}, nil
+	default:
+		if op.text != "=" && op.text != "!=" {
+			break
+		}
+		return &textNode{field: fieldFn, negate: op.text == "!=", value: value.text}, nil
+	}
+
+	return nil, p.errorf(op, "operator '%s' cannot be used with '%s'", op.text, field.text)
+}
@@ -0,0 +1,84 @@
+package mergedlog_test
+
+import (
+	"errors"
+	"merge-logs/mergedlog"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("queries", func() {
+	warning := mergedlog.Fields{
+		Level:   mergedlog.LevelWarning,
+		Member:  "server-1",
+		Thread:  "P2P message reader for server-2",
+		TID:     "0x4f",
+		Message: "Membership: slow receiver",
+	}
+	heartbeat := mergedlog.Fields{
+		Level:   mergedlog.LevelSevere,
+		Member:  "server-2",
+		Thread:  "Geode Heartbeat Sender",
+		TID:     "0x50",
+		Message: "Membership: heartbeat failed",
+	}
+	info := mergedlog.Fields{
+		Level:   mergedlog.LevelInfo,
+		Member:  "server-3",
+		Thread:  "main",
+		TID:     "0x1",
+		Message: "Cache server started",
+	}
+
+	DescribeTable("selecting entries",
+		func(query string, expected ...bool) {
+			q, err := mergedlog.ParseQuery(query)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect([]bool{
+				q.Matches("a", warning),
+				q.Matches("b", heartbeat),
+				q.Matches("c", info),
+			}).To(Equal(expected))
+		},
+		Entry("by minimum level", "level >= warning", true, true, false),
+		Entry("by exact level", "level = info", false, false, true),
+		Entry("by level below", "level<warning", false, false, true),
+		Entry("by member regex", `member ~ "server-[12]"`, true, true, false),
+		Entry("by member", "member = server-3", false, false, true),
+		Entry("by thread ID", "tid = 4F", true, false, false),
+		Entry("by alias", "alias != a", false, true, true),
+		Entry("by message", "msg !~ heartbeat", true, false, true),
+		Entry("with and and not", `level >= warning and member ~ "server-[12]" and not msg ~ "heartbeat"`, true, false, false),
+		Entry("with or", "thread = main or tid = 0x50", false, true, true),
+		Entry("with parentheses", "(member = server-1 or member = server-3) and level >= info", true, false, true),
+		Entry("with and binding more tightly than or", "member = server-3 or member = server-1 and level = severe", false, false, true),
+		Entry("ignoring the case of keywords", "NOT level >= warning AND msg ~ 'started'", false, false, true),
+	)
+
+	It("does not select entries of unknown level by level", func() {
+		q, err := mergedlog.ParseQuery("level < warning")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(q.Matches("a", mergedlog.Fields{})).To(BeFalse())
+	})
+
+	DescribeTable("reporting errors",
+		func(query string, position int) {
+			_, err := mergedlog.ParseQuery(query)
+			Expect(err).Should(HaveOccurred())
+			var queryErr *mergedlog.QueryError
+			Expect(errors.As(err, &queryErr)).To(BeTrue())
+			Expect(queryErr.Pos).To(Equal(position))
+		},
+		Entry("unknown field", "colour = red", 0),
+		Entry("missing operator", "level warning", 6),
+		Entry("missing value", "level >=", 8),
+		Entry("unknown level", "level >= loud", 9),
+		Entry("invalid regex", `msg ~ "slow("`, 6),
+		Entry("ordering text", "member > a", 7),
+		Entry("unterminated string", `msg ~ "slow`, 6),
+		Entry("unbalanced parentheses", "(level = info", 13),
+		Entry("trailing text", "level = info member = a", 13),
+		Entry("dangling and", "level = info and", 16),
+	)
+})
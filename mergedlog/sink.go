@@ -0,0 +1,152 @@
+package mergedlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Sink renders the merged entries written by [Processor.Crank]. Sinks for each of the
+// [OutputFormats] are provided, and others can be given with [Processor.SetSink].
+type Sink interface {
+	// Start is called once, before the first entry
+	Start()
+	// Entry is called with each merged entry, in order
+	Entry(line *LogLine)
+	// Separate is called, when showing context, before an entry which does not follow on from
+	// the previous one
+	Separate()
+	// Flush is called whenever the entries so far should be written out, such as when waiting
+	// for more input while following
+	Flush()
+	// End is called once, after the last entry
+	End()
+}
+
+// GrepMatch is text in a [Span] which matched the grep regex
+type GrepMatch string
+
+// HighlightMatch is text in a [Span] which matched the highlight regex
+type HighlightMatch string
+
+// Markup renders the span, coloring plain text, [GrepMatch] and [HighlightMatch] values with
+// the matching color function
+func (c ColorFn) Markup(span Span) Highlighted {
+	var b strings.Builder
+	for _, value := range span {
+		switch s := value.(type) {
+		case string:
+			b.WriteString(string(c.Normal(s)))
+		case GrepMatch:
+			b.WriteString(string(c.Grep(string(s))))
+		case HighlightMatch:
+			b.WriteString(string(c.Highlight(string(s))))
+		}
+	}
+	return Highlighted(b.String())
+}
+
+// NopSink discards every entry
+type NopSink struct{}
+
+func (NopSink) Start()              {}
+func (NopSink) Entry(line *LogLine) {}
+func (NopSink) Separate()           {}
+func (NopSink) Flush()              {}
+func (NopSink) End()                {}
+
+// TextSink writes each line of an entry prefixed with its alias, in the colors of the palette.
+// See [OUTPUT_TEXT].
+type TextSink struct {
+	writer       io.Writer
+	aliasWidth   int
+	showAdjusted bool
+}
+
+// NewTextSink creates a [TextSink]. Aliases are padded to aliasWidth, as given to
+// [Processor.SetFormat]. If showAdjusted is set, the adjusted timestamp of entries from logs with
+// an offset is written before the entry, in the layout of the log's timestamps.
+func NewTextSink(writer io.Writer, aliasWidth int, showAdjusted bool) *TextSink {
+	return &TextSink{writer: writer, aliasWidth: aliasWidth, showAdjusted: showAdjusted}
+}
+
+func (s *TextSink) Start() {}
+
+func (s *TextSink) Entry(line *LogLine) {
+	for i, span := range line.Text {
+		fmt.Fprintf(s.writer, "%*s[%s] ", len(line.Alias)-s.aliasWidth, "", line.Color.Normal(line.Alias))
+		if i == 0 && s.showAdjusted && line.Offset != 0 {
+			adjusted := line.layout.Format(line.Adjusted)
+			fmt.Fprint(s.writer, line.Color.Normal(fmt.Sprintf("(%s: %s) ", formatOffset(line.Offset), adjusted)))
+		}
+		fmt.Fprintln(s.writer, line.Color.Markup(span))
+	}
+}
+
+func (s *TextSink) Separate() {
+	fmt.Fprintln(s.writer, CONTEXT_SEPARATOR)
+}
+
+func (s *TextSink) Flush() {}
+
+func (s *TextSink) End() {}
+
+// jsonEntry is the JSON representation of a merged entry
+type jsonEntry struct {
+	Alias string `json:"alias"`
+	File  string `json:"file"`
+	Line  int    `json:"line"`
+	// EpochNanos and Timestamp include any clock skew correction
+	EpochNanos int64  `json:"epochNanos"`
+	Timestamp  string `json:"timestamp"`
+	Level      string `json:"level,omitempty"`
+	Member     string `json:"member,omitempty"`
+	Thread     string `json:"thread,omitempty"`
+	TID        string `json:"tid,omitempty"`
+	Message    string `json:"message"`
+	Text       string `json:"text"`
+	Context    bool   `json:"context,omitempty"`
+}
+
+// JSONSink writes each entry as a line of JSON, without any color. See [OUTPUT_JSON].
+type JSONSink struct {
+	encoder *json.Encoder
+}
+
+// NewJSONSink creates a [JSONSink] writing to writer
+func NewJSONSink(writer io.Writer) *JSONSink {
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+	return &JSONSink{encoder: encoder}
+}
+
+func (s *JSONSink) Start() {}
+
+func (s *JSONSink) Entry(line *LogLine) {
+	entry := jsonEntry{
+		Alias:      strings.TrimSuffix(line.Alias, "*"),
+		File:       line.File,
+		Line:       line.LineNumber,
+		EpochNanos: line.UTime,
+		Timestamp:  line.Adjusted.Format(time.RFC3339Nano),
+		Member:     line.Fields.Member,
+		Thread:     line.Fields.Thread,
+		TID:        line.Fields.TID,
+		Message:    line.Fields.Message,
+		Text:       line.Raw,
+		Context:    line.isContext,
+	}
+	if line.Fields.Level != LevelUnknown {
+		entry.Level = line.Fields.Level.String()
+	}
+	s.encoder.Encode(entry)
+}
+
+// Separate does nothing, since context entries are marked instead
+func (s *JSONSink) Separate() {}
+
+func (s *JSONSink) Flush() {}
+
+func (s *JSONSink) End() {}
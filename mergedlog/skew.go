@@ -0,0 +1,274 @@
+package mergedlog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"text/tabwriter"
+	"time"
+)
+
+// membershipEvent describes a kind of log message that is one half of a message exchange
+// between members. The first subexpression captures a key identifying the exchange, so that the
+// sending and receiving halves, logged by different members, can be paired up.
+type membershipEvent struct {
+	exchange string
+	send     bool
+	re       *regexp.Regexp
+}
+
+// memberIDPattern matches the stable part of a member ID, without the view and port, such as
+// "7f6dea03(server1:9238:locator)" or "192.168.1.5(server1:4567)"
+const memberIDPattern = `([\w.:-]+\([^)]*\))`
+
+var membershipEvents = []membershipEvent{
+	// The membership coordinator prepares and then sends each new view...
+	{"view", true, regexp.MustCompile(`(?:[Pp]reparing|[Ss]ending) new (?:membership )?view.*?View\[[^|\]]*\|(\d+)\]`)},
+	// ...which every other member then logs as having been received
+	{"view", false, regexp.MustCompile(`[Rr]eceived new (?:membership )?view.*?View\[[^|\]]*\|(\d+)\]`)},
+	// A joining member sends a join request to the coordinator...
+	{"join-request", true, regexp.MustCompile(`[Aa]ttempting to join the distributed system through coordinator .*? using address ` + memberIDPattern)},
+	{"join-request", false, regexp.MustCompile(`[Rr]eceived (?:a )?join request from ` + memberIDPattern)},
+	// ...which answers with a join response
+	{"join-response", true, regexp.MustCompile(`[Ss]ending (?:a )?join response .*?to ` + memberIDPattern)},
+	{"join-response", false, regexp.MustCompile(`[Rr]eceived (?:a )?join response .*?(?:for|memberID=) ?` + memberIDPattern)},
+}
+
+// SkewEstimator estimates the clock offsets between members by pairing up messages logged when
+// a membership view, join request or join response is sent by one member and received by
+// another. A message cannot be received before it was sent, so every pair bounds the difference
+// between the two members' clocks.
+type SkewEstimator struct {
+	aliases []string
+	// sends and receives hold, per exchange key, the earliest send and latest receive per alias
+	sends    map[string]map[string]int64
+	receives map[string]map[string]int64
+}
+
+// SkewEstimate is the estimated correction for one member's clock, relative to the reference
+// member. Adding Offset to the member's timestamps aligns them with the reference.
+type SkewEstimate struct {
+	Alias  string
+	Offset time.Duration
+	// Lower and Upper bound the offset when HasLower and HasUpper are set
+	Lower    time.Duration
+	Upper    time.Duration
+	HasLower bool
+	HasUpper bool
+	// Pairs is the number of message pairs supporting the estimate
+	Pairs int
+	// Contradictory is set when the lower bound is above the upper bound, so no offset can put
+	// every message pair in causal order; for example because a clock was adjusted while the
+	// logs were written. The offset is then the middle of the bounds.
+	Contradictory bool
+}
+
+func NewSkewEstimator() *SkewEstimator {
+	return &SkewEstimator{
+		sends:    make(map[string]map[string]int64),
+		receives: make(map[string]map[string]int64),
+	}
+}
+
+// AddLog scans a log for membership messages. Logs from the same member (for example rolled
+// logs) should be added with the same alias. The first alias added is the reference member
+// against which offsets are estimated.
+func (e *SkewEstimator) AddLog(format LogFormat, alias string, reader io.Reader, maxBuffer int) error {
+	if !e.known(alias) {
+		e.aliases = append(e.aliases, alias)
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(format.Split)
+	scanner.Buffer(make([]byte, bufio.MaxScanTokenSize), maxBuffer)
+
+	header := format.Header()
+	timestampIndex := header.SubexpIndex("timestamp")
+	timestamps := timestampParser{layouts: format.Layouts()}
+
+	for scanner.Scan() {
+		entry := scanner.Text()
+		matches := header.FindStringSubmatch(entry)
+		if matches == nil {
+			continue
+		}
+
+		for _, event := range membershipEvents {
+			m := event.re.FindStringSubmatch(entry)
+			if m == nil {
+				continue
+			}
+
+			t, _, _, _, err := timestamps.parse(matches[timestampIndex])
+			if err != nil {
+				break
+			}
+
+			key := event.exchange + ":" + m[1]
+			if event.send {
+				record(e.sends, key, alias, t.UnixNano(), func(old, new int64) bool { return new < old })
+			} else {
+				record(e.receives, key, alias, t.UnixNano(), func(old, new int64) bool { return new > old })
+			}
+			break
+		}
+	}
+
+	return scanner.Err()
+}
+
+func (e *SkewEstimator) known(alias string) bool {
+	for _, a := range e.aliases {
+		if a == alias {
+			return true
+		}
+	}
+	return false
+}
+
+func record(events map[string]map[string]int64, key string, alias string, utime int64, replace func(old, new int64) bool) {
+	byAlias, ok := events[key]
+	if !ok {
+		byAlias = make(map[string]int64)
+		events[key] = byAlias
+	}
+	if old, ok := byAlias[alias]; !ok || replace(old, utime) {
+		byAlias[alias] = utime
+	}
+}
+
+// pairBounds holds the constraints on the offset of member b relative to member a
+type pairBounds struct {
+	lower, upper       int64
+	hasLower, hasUpper bool
+	pairs              int
+}
+
+func (b *pairBounds) estimate() int64 {
+	switch {
+	case b.hasLower && b.hasUpper:
+		return (b.lower + b.upper) / 2
+	case b.hasLower:
+		// Only correct as much as is needed to restore causality
+		return max(b.lower, 0)
+	default:
+		return min(b.upper, 0)
+	}
+}
+
+// Estimate returns the estimated offsets of every member that could be related to the
+// reference member, starting with the reference member itself.
+func (e *SkewEstimator) Estimate() []SkewEstimate {
+	// bounds[a][b] constrains offset(b) - offset(a)
+	bounds := make(map[string]map[string]*pairBounds)
+	get := func(a, b string) *pairBounds {
+		if bounds[a] == nil {
+			bounds[a] = make(map[string]*pairBounds)
+		}
+		if bounds[a][b] == nil {
+			bounds[a][b] = &pairBounds{}
+		}
+		return bounds[a][b]
+	}
+
+	for key, senders := range e.sends {
+		for sender, sent := range senders {
+			for receiver, received := range e.receives[key] {
+				if receiver == sender {
+					continue
+				}
+				// received + offset(receiver) >= sent + offset(sender)
+				limit := sent - received
+
+				forward := get(sender, receiver)
+				if !forward.hasLower || limit > forward.lower {
+					forward.lower = limit
+					forward.hasLower = true
+				}
+				forward.pairs++
+
+				reverse := get(receiver, sender)
+				if !reverse.hasUpper || -limit < reverse.upper {
+					reverse.upper = -limit
+					reverse.hasUpper = true
+				}
+				reverse.pairs++
+			}
+		}
+	}
+
+	if len(e.aliases) == 0 {
+		return nil
+	}
+
+	// Walk outwards from the reference member, accumulating offsets
+	reference := e.aliases[0]
+	estimates := []SkewEstimate{{Alias: reference}}
+	offsets := map[string]int64{reference: 0}
+	for i := 0; i < len(estimates); i++ {
+		from := estimates[i].Alias
+		for _, to := range e.aliases {
+			b := bounds[from][to]
+			if _, done := offsets[to]; done || b == nil {
+				continue
+			}
+
+			base := offsets[from]
+			offsets[to] = base + b.estimate()
+			estimates = append(estimates, SkewEstimate{
+				Alias:         to,
+				Offset:        time.Duration(offsets[to]),
+				Lower:         time.Duration(base + b.lower),
+				Upper:         time.Duration(base + b.upper),
+				HasLower:      b.hasLower,
+				HasUpper:      b.hasUpper,
+				Pairs:         b.pairs,
+				Contradictory: b.hasLower && b.hasUpper && b.lower > b.upper,
+			})
+		}
+	}
+
+	return estimates
+}
+
+// Report writes the estimated offsets in a human-readable form, including members for which no
+// estimate could be made.
+func (e *SkewEstimator) Report(writer io.Writer) {
+	estimates := e.Estimate()
+	if len(estimates) == 0 {
+		fmt.Fprintln(writer, "No logs to estimate clock offsets from")
+		return
+	}
+
+	fmt.Fprintf(writer, "Estimated clock offsets relative to %s:\n", estimates[0].Alias)
+	w := tabwriter.NewWriter(writer, 0, 4, 2, ' ', 0)
+	found := make(map[string]bool)
+	for _, estimate := range estimates {
+		found[estimate.Alias] = true
+		fmt.Fprintf(w, "  %s\t%s\t", estimate.Alias, formatOffset(estimate.Offset))
+		switch {
+		case estimate.Pairs == 0:
+			fmt.Fprint(w, "(reference)")
+		case estimate.HasLower && estimate.HasUpper:
+			fmt.Fprintf(w, "(between %s and %s", formatOffset(estimate.Lower), formatOffset(estimate.Upper))
+		case estimate.HasLower:
+			fmt.Fprintf(w, "(at least %s", formatOffset(estimate.Lower))
+		default:
+			fmt.Fprintf(w, "(at most %s", formatOffset(estimate.Upper))
+		}
+		if estimate.Pairs > 0 {
+			fmt.Fprintf(w, ", %d message pairs)", estimate.Pairs)
+		}
+		if estimate.Contradictory {
+			fmt.Fprint(w, " WARNING: the bounds contradict each other, so the offset is unreliable")
+		}
+		fmt.Fprintln(w)
+	}
+	for _, alias := range e.aliases {
+		if !found[alias] {
+			fmt.Fprintf(w, "  %s\tunknown\t(no membership messages shared with other members)\n", alias)
+		}
+	}
+	w.Flush()
+}
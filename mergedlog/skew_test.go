@@ -0,0 +1,121 @@
+package mergedlog_test
+
+import (
+	"merge-logs/mergedlog"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const view1 = "View[locator(locator:1)<v0>:1024|1] members: [locator, server1]"
+const view2 = "View[server1(server1:2)<v1>:1025|2] members: [locator, server1]"
+
+var _ = Describe("estimating clock skew", func() {
+	var estimator *mergedlog.SkewEstimator
+
+	addLog := func(alias string, content string) {
+		Expect(estimator.AddLog(mergedlog.GemFireFormat, alias, strings.NewReader(content), 1024*1024)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		estimator = mergedlog.NewSkewEstimator()
+	})
+
+	It("corrects a member which receives a view before it was sent", func() {
+		addLog("locator", `[info 2015/11/19 08:52:39.500 UTC locator <View Creator> tid=0x1] sending new view `+view1)
+		addLog("server1", `[info 2015/11/19 08:52:39.300 UTC server1 <unicast receiver> tid=0x1] received new view: `+view1)
+
+		Expect(estimator.Estimate()).To(Equal([]mergedlog.SkewEstimate{
+			{Alias: "locator"},
+			{Alias: "server1", Offset: 200 * time.Millisecond, Lower: 200 * time.Millisecond, HasLower: true, Pairs: 1},
+		}))
+	})
+
+	It("does not correct a member whose messages are already causally ordered", func() {
+		addLog("locator", `[info 2015/11/19 08:52:39.500 UTC locator <View Creator> tid=0x1] sending new view `+view1)
+		addLog("server1", `[info 2015/11/19 08:52:39.600 UTC server1 <unicast receiver> tid=0x1] received new view: `+view1)
+
+		estimates := estimator.Estimate()
+		Expect(estimates).To(HaveLen(2))
+		Expect(estimates[1].Offset).To(Equal(time.Duration(0)))
+	})
+
+	It("takes the middle of the bounds when messages go both ways", func() {
+		addLog("locator", `[info 2015/11/19 08:52:39.500 UTC locator <View Creator> tid=0x1] sending new view `+view1+`
+[info 2015/11/19 08:52:42.000 UTC locator <unicast receiver> tid=0x1] received new view: `+view2)
+		addLog("server1", `[info 2015/11/19 08:52:39.600 UTC server1 <unicast receiver> tid=0x1] received new view: `+view1+`
+[info 2015/11/19 08:52:41.500 UTC server1 <View Creator> tid=0x1] sending new view `+view2)
+
+		// server1 needs correcting by between -100ms and +500ms
+		Expect(estimator.Estimate()[1]).To(Equal(mergedlog.SkewEstimate{
+			Alias:    "server1",
+			Offset:   200 * time.Millisecond,
+			Lower:    -100 * time.Millisecond,
+			Upper:    500 * time.Millisecond,
+			HasLower: true,
+			HasUpper: true,
+			Pairs:    2,
+		}))
+	})
+
+	It("accumulates offsets through intermediate members", func() {
+		addLog("locator", `[info 2015/11/19 08:52:39.500 UTC locator <View Creator> tid=0x1] sending new view `+view1)
+		addLog("server1", `[info 2015/11/19 08:52:39.400 UTC server1 <unicast receiver> tid=0x1] received new view: `+view1+`
+[info 2015/11/19 08:52:41.000 UTC server1 <View Creator> tid=0x1] sending new view `+view2)
+		addLog("server2", `[info 2015/11/19 08:52:40.800 UTC server2 <unicast receiver> tid=0x1] received new view: `+view2)
+
+		estimates := estimator.Estimate()
+		Expect(estimates).To(HaveLen(3))
+		Expect(estimates[1].Alias).To(Equal("server1"))
+		Expect(estimates[1].Offset).To(Equal(100 * time.Millisecond))
+		Expect(estimates[2].Alias).To(Equal("server2"))
+		Expect(estimates[2].Offset).To(Equal(300 * time.Millisecond))
+	})
+
+	It("reports members without shared messages as unknown", func() {
+		addLog("locator", `[info 2015/11/19 08:52:39.500 UTC locator <View Creator> tid=0x1] sending new view `+view1)
+		addLog("server1", `[info 2015/11/19 08:52:39.300 UTC server1 <unicast receiver> tid=0x1] received new view: `+view1)
+		addLog("client", `[info 2015/11/19 08:52:39.300 UTC client <main> tid=0x1] connected`)
+
+		report := &strings.Builder{}
+		estimator.Report(report)
+		Expect(report.String()).To(ContainSubstring("Estimated clock offsets relative to locator"))
+		Expect(report.String()).To(MatchRegexp(`server1 +\+200ms +\(at least \+200ms, 1 message pairs\)`))
+		Expect(report.String()).To(MatchRegexp(`client +unknown`))
+	})
+
+	It("pairs join requests and responses", func() {
+		addLog("locator", `[info 2015/11/19 08:52:39.500 UTC locator <unicast receiver> tid=0x1] Received a join request from 192.168.1.5(server1:4567)<v1>:41001
+[info 2015/11/19 08:52:39.600 UTC locator <View Creator> tid=0x1] Sending join response JoinResponse(...) to 192.168.1.5(server1:4567)<v1>:41001`)
+		addLog("server1", `[info 2015/11/19 08:52:39.800 UTC server1 <main> tid=0x1] Attempting to join the distributed system through coordinator 192.168.1.4(locator:1234:locator)<ec><v0>:41000 using address 192.168.1.5(server1:4567):41001
+[info 2015/11/19 08:52:40.000 UTC server1 <main> tid=0x1] Received join response for 192.168.1.5(server1:4567)<v1>:41001`)
+
+		// server1's request was received 300ms before it was sent, and the response was received
+		// 400ms after it was sent, so server1 needs correcting by between -400ms and -300ms
+		Expect(estimator.Estimate()[1]).To(Equal(mergedlog.SkewEstimate{
+			Alias:    "server1",
+			Offset:   -350 * time.Millisecond,
+			Lower:    -400 * time.Millisecond,
+			Upper:    -300 * time.Millisecond,
+			HasLower: true,
+			HasUpper: true,
+			Pairs:    2,
+		}))
+	})
+
+	It("warns about contradictory bounds", func() {
+		addLog("locator", `[info 2015/11/19 08:52:39.500 UTC locator <View Creator> tid=0x1] sending new view `+view1+`
+[info 2015/11/19 08:52:42.000 UTC locator <unicast receiver> tid=0x1] received new view: `+view2)
+		addLog("server1", `[info 2015/11/19 08:52:39.300 UTC server1 <unicast receiver> tid=0x1] received new view: `+view1+`
+[info 2015/11/19 08:52:42.500 UTC server1 <View Creator> tid=0x1] sending new view `+view2)
+
+		estimates := estimator.Estimate()
+		Expect(estimates[1].Contradictory).To(BeTrue())
+
+		report := &strings.Builder{}
+		estimator.Report(report)
+		Expect(report.String()).To(MatchRegexp(`server1 .*WARNING: the bounds contradict each other`))
+	})
+})
@@ -0,0 +1,399 @@
+package mergedlog
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LogSource is a single log stream to be merged. It may be a plain file or an entry inside an
+// archive.
+type LogSource struct {
+	// Name identifies the log. For archive entries this is the archive path joined with the
+	// entry name, for example "exported.zip/server1/server1.log".
+	Name string
+	// Tag is derived from the source's location (for example the member directory inside an
+	// archive) and is nil if none could be determined.
+	Tag  *string
+	open func() (io.ReadCloser, error)
+	// follow is set for sources which can be followed as they grow
+	follow func(interval time.Duration) (io.ReadCloser, error)
+}
+
+// Open returns a reader for the (decompressed) content of the source.
+func (s *LogSource) Open() (io.ReadCloser, error) {
+	return s.open()
+}
+
+// Follow returns a reader which keeps reading the source as it grows. See [FollowLog]. Only
+// plain files and standard input can be followed.
+func (s *LogSource) Follow(interval time.Duration) (io.ReadCloser, error) {
+	if s.follow == nil {
+		return nil, fmt.Errorf("%s: archive entries cannot be followed", s.Name)
+	}
+	return s.follow(interval)
+}
+
+var zipMagic = []byte("PK\x03\x04")
+
+const tarMagicOffset = 257
+
+var tarMagic = []byte("ustar")
+
+// FileFilter selects which files are picked up when walking a directory or an archive. Globs
+// are matched against the base name of each file, ignoring any compression extension.
+type FileFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// DefaultFileFilter picks up GemFire logs but skips GC logs and statistics archives
+var DefaultFileFilter = &FileFilter{
+	Include: []string{"*.log"},
+	Exclude: []string{"*gc*.log", "*.gfs"},
+}
+
+// Matches determines whether the named file should be treated as a log
+func (f *FileFilter) Matches(name string) bool {
+	if f.excludes(name) {
+		return false
+	}
+
+	base := trimCompressionSuffix(path.Base(filepath.ToSlash(name)))
+	for _, glob := range f.Include {
+		if matched, _ := path.Match(glob, base); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// excludes determines whether the named file is rejected by one of the exclude globs
+func (f *FileFilter) excludes(name string) bool {
+	base := trimCompressionSuffix(path.Base(filepath.ToSlash(name)))
+	for _, glob := range f.Exclude {
+		if matched, _ := path.Match(glob, base); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// FindLogSources returns the log sources contained in the named file or directory. The name
+// [STDIN_NAME] refers to standard input. Directories are walked recursively, picking up files
+// accepted by the filter and tagging each with the name of its parent directory. Zip and tar
+// archives (optionally compressed) are expanded into one source per log entry; any other file
+// is returned as a single source. A nil filter uses [DefaultFileFilter].
+func FindLogSources(name string, filter *FileFilter) ([]*LogSource, error) {
+	if filter == nil {
+		filter = DefaultFileFilter
+	}
+
+	if name == STDIN_NAME {
+		return []*LogSource{stdinSource()}, nil
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return dirSources(name, filter)
+	}
+	// Pipes and devices can only be read once so cannot be sniffed for archive content
+	if !info.Mode().IsRegular() {
+		return []*LogSource{fileSource(name)}, nil
+	}
+
+	kind, err := sniffArchive(name)
+	if err != nil {
+		return nil, err
+	}
+	if kind != "" {
+		return archiveSources(name, kind, filter)
+	}
+
+	return []*LogSource{fileSource(name)}, nil
+}
+
+// archiveSources returns the log entries, accepted by the filter, of the named archive. The kind
+// is as returned by [sniffArchive].
+func archiveSources(name string, kind string, filter *FileFilter) ([]*LogSource, error) {
+	if kind == "zip" {
+		return zipSources(name, filter)
+	}
+	return tarSources(name, filter)
+}
+
+// dirSources walks the named directory for logs. A log, along with its rolled files, is tagged
+// with the name of its parent directory, unless the directory holds several different logs, in
+// which case they are left to be told apart by their file names.
+func dirSources(name string, filter *FileFilter) ([]*LogSource, error) {
+	var sources []*LogSource
+	// untagged holds, per directory, the logs which have no tag of their own
+	untagged := make(map[string][]*LogSource)
+
+	err := filepath.WalkDir(name, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filter.excludes(p) {
+			return nil
+		}
+
+		// Archives are expanded whatever their name, with the globs applied to their entries
+		var found []*LogSource
+		if filter.Matches(p) {
+			found, err = FindLogSources(p, filter)
+		} else if !d.Type().IsRegular() {
+			return nil
+		} else if kind, _ := sniffArchive(p); kind != "" {
+			found, err = archiveSources(p, kind, filter)
+		}
+		if err != nil {
+			return err
+		}
+
+		dir := filepath.Dir(p)
+		for _, source := range found {
+			// Archive entries carry a more specific member tag already
+			if source.Tag == nil {
+				untagged[dir] = append(untagged[dir], source)
+			}
+		}
+		sources = append(sources, found...)
+
+		return nil
+	})
+
+	for dir, logs := range untagged {
+		names := make(map[string]bool)
+		for _, source := range logs {
+			names[ShortenFilename(trimCompressionSuffix(source.Name), false)] = true
+		}
+		if len(names) > 1 {
+			continue
+		}
+		tag := filepath.Base(dir)
+		for _, source := range logs {
+			source.Tag = &tag
+		}
+	}
+
+	return sources, err
+}
+
+func fileSource(name string) *LogSource {
+	return &LogSource{
+		Name: name,
+		open: func() (io.ReadCloser, error) { return OpenLog(name) },
+		follow: func(interval time.Duration) (io.ReadCloser, error) {
+			// Compressed files are assumed to be complete, already rolled, logs
+			if trimCompressionSuffix(name) != name {
+				return OpenLog(name)
+			}
+			return FollowLog(name, interval)
+		},
+	}
+}
+
+// stdinSource reads from standard input, which can only be consumed once. Reads from a pipe
+// naturally block until more data is written, so following is the same as opening.
+func stdinSource() *LogSource {
+	var opened bool
+	open := func() (io.ReadCloser, error) {
+		if opened {
+			return nil, fmt.Errorf("standard input can only be read once")
+		}
+		opened = true
+
+		reader, closer, err := Decompress(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("stdin: %w", err)
+		}
+		return &logReader{Reader: reader, closers: []io.Closer{closer}}, nil
+	}
+
+	return &LogSource{
+		Name:   "stdin",
+		open:   open,
+		follow: func(time.Duration) (io.ReadCloser, error) { return open() },
+	}
+}
+
+// sniffArchive returns "zip", "tar" or "" depending on the content of the named file.
+func sniffArchive(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	reader, closer, err := Decompress(f)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	defer closer.Close()
+
+	header := make([]byte, tarMagicOffset+len(tarMagic))
+	n, err := io.ReadFull(reader, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	header = header[:n]
+
+	if bytes.HasPrefix(header, zipMagic) {
+		return "zip", nil
+	}
+	if len(header) == cap(header) && bytes.Equal(header[tarMagicOffset:], tarMagic) {
+		return "tar", nil
+	}
+
+	return "", nil
+}
+
+// memberTag derives a tag from the directory containing an archive entry. Entries at the top
+// level of the archive have no tag.
+func memberTag(entryName string) *string {
+	dir := path.Dir(entryName)
+	if dir == "." || dir == "/" {
+		return nil
+	}
+	tag := path.Base(dir)
+	return &tag
+}
+
+func zipSources(name string, filter *FileFilter) ([]*LogSource, error) {
+	archive, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	defer archive.Close()
+
+	var sources []*LogSource
+	for i, entry := range archive.File {
+		if entry.FileInfo().IsDir() || !filter.Matches(entry.Name) {
+			continue
+		}
+
+		index := i
+		sources = append(sources, &LogSource{
+			Name: name + "/" + entry.Name,
+			Tag:  memberTag(entry.Name),
+			open: func() (io.ReadCloser, error) { return openZipEntry(name, index) },
+		})
+	}
+
+	return sources, nil
+}
+
+func openZipEntry(name string, index int) (io.ReadCloser, error) {
+	archive, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	entry, err := archive.File[index].Open()
+	if err != nil {
+		archive.Close()
+		return nil, fmt.Errorf("%s/%s: %w", name, archive.File[index].Name, err)
+	}
+
+	reader, closer, err := Decompress(entry)
+	if err != nil {
+		entry.Close()
+		archive.Close()
+		return nil, fmt.Errorf("%s/%s: %w", name, archive.File[index].Name, err)
+	}
+
+	return &logReader{Reader: reader, closers: []io.Closer{closer, entry, archive}}, nil
+}
+
+// openTar returns a tar reader over the (decompressed) content of the named file
+func openTar(name string) (*tar.Reader, *logReader, error) {
+	f, err := OpenLog(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	lr := f.(*logReader)
+	return tar.NewReader(bufio.NewReader(lr)), lr, nil
+}
+
+// tarSources reads the archive in a single pass, copying each log entry to a temporary file. Tar
+// archives can only be read sequentially, so this avoids decompressing the archive again for
+// every entry, and every time an entry is opened, without holding the entries in memory.
+func tarSources(name string, filter *FileFilter) ([]*LogSource, error) {
+	tr, f, err := openTar(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var sources []*LogSource
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		if header.Typeflag != tar.TypeReg || !filter.Matches(header.Name) {
+			continue
+		}
+
+		entryName := strings.TrimPrefix(header.Name, "./")
+		content, err := spoolTarEntry(tr)
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s: %w", name, entryName, err)
+		}
+
+		sources = append(sources, &LogSource{
+			Name: name + "/" + entryName,
+			Tag:  memberTag(entryName),
+			open: func() (io.ReadCloser, error) { return openTarEntry(name, entryName, content) },
+		})
+	}
+
+	return sources, nil
+}
+
+// spoolTarEntry copies the current entry of the tar to a temporary file. The file is removed
+// straight away, so that it goes once the process exits; on platforms which do not allow removing
+// an open file it is left for the system to clean up.
+func spoolTarEntry(tr *tar.Reader) (*io.SectionReader, error) {
+	f, err := os.CreateTemp("", "merge-logs-*")
+	if err != nil {
+		return nil, err
+	}
+	os.Remove(f.Name())
+
+	size, err := io.Copy(f, tr)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return io.NewSectionReader(f, 0, size), nil
+}
+
+// openTarEntry returns a reader for the spooled content of a tar entry, decompressing it if
+// needed
+func openTarEntry(name string, entryName string, content *io.SectionReader) (io.ReadCloser, error) {
+	reader, closer, err := Decompress(io.NewSectionReader(content, 0, content.Size()))
+	if err != nil {
+		return nil, fmt.Errorf("%s/%s: %w", name, entryName, err)
+	}
+
+	return &logReader{Reader: reader, closers: []io.Closer{closer}}, nil
+}
@@ -0,0 +1,222 @@
+package mergedlog_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"merge-logs/mergedlog"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var archiveEntries = map[string]string{
+	"server1/server1.log":       "[info 2015/11/19 08:52:39.504 PST  server1 line\n",
+	"server2/server2-01-02.log": "[info 2015/11/19 08:52:39.505 PST  server2 line\n",
+	"server2/stats.gfs":         "not a log",
+	"top.log":                   "[info 2015/11/19 08:52:39.506 PST  top line\n",
+}
+
+func readSource(source *mergedlog.LogSource) string {
+	reader, err := source.Open()
+	Expect(err).ShouldNot(HaveOccurred())
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	Expect(err).ShouldNot(HaveOccurred())
+	return string(content)
+}
+
+func expectArchiveSources(archive string, sources []*mergedlog.LogSource) {
+	Expect(sources).To(HaveLen(3))
+
+	byName := make(map[string]*mergedlog.LogSource)
+	for _, s := range sources {
+		byName[s.Name] = s
+	}
+
+	s1 := byName[archive+"/server1/server1.log"]
+	Expect(s1).ToNot(BeNil())
+	Expect(*s1.Tag).To(Equal("server1"))
+	Expect(readSource(s1)).To(Equal(archiveEntries["server1/server1.log"]))
+
+	s2 := byName[archive+"/server2/server2-01-02.log"]
+	Expect(s2).ToNot(BeNil())
+	Expect(*s2.Tag).To(Equal("server2"))
+	Expect(readSource(s2)).To(Equal(archiveEntries["server2/server2-01-02.log"]))
+
+	top := byName[archive+"/top.log"]
+	Expect(top).ToNot(BeNil())
+	Expect(top.Tag).To(BeNil())
+	Expect(readSource(top)).To(Equal(archiveEntries["top.log"]))
+}
+
+func writeZip(name string) {
+	f, err := os.Create(name)
+	Expect(err).ShouldNot(HaveOccurred())
+	w := zip.NewWriter(f)
+	for entry, content := range archiveEntries {
+		ew, err := w.Create(entry)
+		Expect(err).ShouldNot(HaveOccurred())
+		ew.Write([]byte(content))
+	}
+	Expect(w.Close()).To(Succeed())
+	Expect(f.Close()).To(Succeed())
+}
+
+var _ = Describe("finding log sources", func() {
+	var dir string
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+	})
+
+	It("returns a plain file as a single source", func() {
+		name := filepath.Join(dir, "server.log")
+		Expect(os.WriteFile(name, []byte("content"), 0644)).To(Succeed())
+
+		sources, err := mergedlog.FindLogSources(name, nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(sources).To(HaveLen(1))
+		Expect(sources[0].Name).To(Equal(name))
+		Expect(sources[0].Tag).To(BeNil())
+		Expect(readSource(sources[0])).To(Equal("content"))
+	})
+
+	It("returns stdin as a single source", func() {
+		sources, err := mergedlog.FindLogSources(mergedlog.STDIN_NAME, nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(sources).To(HaveLen(1))
+		Expect(sources[0].Name).To(Equal("stdin"))
+		Expect(sources[0].Tag).To(BeNil())
+	})
+
+	It("expands log entries in a zip archive", func() {
+		name := filepath.Join(dir, "exported.zip")
+		writeZip(name)
+
+		sources, err := mergedlog.FindLogSources(name, nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		expectArchiveSources(name, sources)
+	})
+
+	It("expands log entries in a compressed tar archive", func() {
+		name := filepath.Join(dir, "bundle.tar.gz")
+		f, err := os.Create(name)
+		Expect(err).ShouldNot(HaveOccurred())
+		gz := gzip.NewWriter(f)
+		w := tar.NewWriter(gz)
+		for entry, content := range archiveEntries {
+			Expect(w.WriteHeader(&tar.Header{
+				Name:     entry,
+				Mode:     0644,
+				Size:     int64(len(content)),
+				Typeflag: tar.TypeReg,
+			})).To(Succeed())
+			w.Write([]byte(content))
+		}
+		Expect(w.Close()).To(Succeed())
+		Expect(gz.Close()).To(Succeed())
+		Expect(f.Close()).To(Succeed())
+
+		sources, err := mergedlog.FindLogSources(name, nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		expectArchiveSources(name, sources)
+
+		// The archive has been read once, but entries can still be opened again, in any order
+		Expect(os.Remove(name)).To(Succeed())
+		expectArchiveSources(name, sources)
+
+		// An entry opened more than once is read independently each time
+		first, err := sources[0].Open()
+		Expect(err).ShouldNot(HaveOccurred())
+		defer first.Close()
+		start := make([]byte, 5)
+		Expect(io.ReadFull(first, start)).To(Equal(5))
+		content := readSource(sources[0])
+		rest, err := io.ReadAll(first)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(string(start) + string(rest)).To(Equal(content))
+	})
+
+	It("walks directories picking up matching files", func() {
+		for entry, content := range archiveEntries {
+			name := filepath.Join(dir, "logs", entry)
+			Expect(os.MkdirAll(filepath.Dir(name), 0755)).To(Succeed())
+			Expect(os.WriteFile(name, []byte(content), 0644)).To(Succeed())
+		}
+		Expect(os.WriteFile(filepath.Join(dir, "logs", "server1", "gc.log"), []byte("gc"), 0644)).To(Succeed())
+
+		sources, err := mergedlog.FindLogSources(filepath.Join(dir, "logs"), nil)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		tags := make(map[string]string)
+		for _, s := range sources {
+			tags[s.Name] = *s.Tag
+		}
+		Expect(tags).To(Equal(map[string]string{
+			filepath.Join(dir, "logs", "server1", "server1.log"):       "server1",
+			filepath.Join(dir, "logs", "server2", "server2-01-02.log"): "server2",
+			filepath.Join(dir, "logs", "top.log"):                      "logs",
+		}))
+	})
+
+	It("tags the logs of a flat directory by their file names", func() {
+		Expect(os.MkdirAll(filepath.Join(dir, "logs", "server3"), 0755)).To(Succeed())
+		for _, entry := range []string{"server1.log", "server2.log", "locator.log",
+			"server3/cacheserver.log", "server3/cacheserver-01-02.log.gz"} {
+			name := filepath.Join(dir, "logs", entry)
+			Expect(os.WriteFile(name, []byte("[info 2015/11/19 08:52:39.504 PST  line\n"), 0644)).To(Succeed())
+		}
+
+		sources, err := mergedlog.FindLogSources(filepath.Join(dir, "logs"), nil)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		tags := make(map[string]*string)
+		for _, s := range sources {
+			tags[s.Name] = s.Tag
+		}
+		Expect(tags).To(HaveLen(5))
+		Expect(tags[filepath.Join(dir, "logs", "server1.log")]).To(BeNil())
+		Expect(tags[filepath.Join(dir, "logs", "server2.log")]).To(BeNil())
+		Expect(tags[filepath.Join(dir, "logs", "locator.log")]).To(BeNil())
+		// Rolled files are the same log, so still take the directory's name
+		Expect(*tags[filepath.Join(dir, "logs", "server3", "cacheserver.log")]).To(Equal("server3"))
+		Expect(*tags[filepath.Join(dir, "logs", "server3", "cacheserver-01-02.log.gz")]).To(Equal("server3"))
+	})
+
+	It("expands archives found when walking directories", func() {
+		Expect(os.MkdirAll(filepath.Join(dir, "logs", "locator"), 0755)).To(Succeed())
+		name := filepath.Join(dir, "logs", "locator", "exported.zip")
+		writeZip(name)
+
+		sources, err := mergedlog.FindLogSources(filepath.Join(dir, "logs"), nil)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		tags := make(map[string]string)
+		for _, s := range sources {
+			tags[s.Name] = *s.Tag
+		}
+		Expect(tags).To(Equal(map[string]string{
+			name + "/server1/server1.log":       "server1",
+			name + "/server2/server2-01-02.log": "server2",
+			name + "/top.log":                   "locator",
+		}))
+	})
+
+	It("applies include and exclude globs", func() {
+		filter := &mergedlog.FileFilter{
+			Include: []string{"*.log", "*.gfs"},
+			Exclude: []string{"*gc*.log"},
+		}
+
+		Expect(filter.Matches("a/b/server1.log")).To(BeTrue())
+		Expect(filter.Matches("a/b/server1-01-02.log.gz")).To(BeTrue())
+		Expect(filter.Matches("a/b/stats.gfs")).To(BeTrue())
+		Expect(filter.Matches("a/b/server1-gc.log")).To(BeFalse())
+		Expect(filter.Matches("a/b/notes.txt")).To(BeFalse())
+	})
+})
@@ -0,0 +1,62 @@
+package mergedlog
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ThreadFilter selects entries logged by particular threads, chosen by name or by thread ID.
+// Entries whose thread is not known are never selected.
+type ThreadFilter struct {
+	name *regexp.Regexp
+	tids map[uint64]bool
+}
+
+// NewThreadFilter creates a filter selecting entries whose thread name matches the regular
+// expression or whose thread ID is one of the given IDs. Either may be empty. Thread IDs are
+// hexadecimal, with or without the "0x" prefix.
+func NewThreadFilter(name string, tids []string) (*ThreadFilter, error) {
+	filter := &ThreadFilter{tids: make(map[uint64]bool)}
+
+	if name != "" {
+		re, err := regexp.Compile(name)
+		if err != nil {
+			return nil, fmt.Errorf("thread: %w", err)
+		}
+		filter.name = re
+	}
+
+	for _, tid := range tids {
+		id, err := parseTID(tid)
+		if err != nil {
+			return nil, err
+		}
+		filter.tids[id] = true
+	}
+
+	return filter, nil
+}
+
+func parseTID(tid string) (uint64, error) {
+	digits := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(tid)), "0x")
+	id, err := strconv.ParseUint(digits, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("thread ID '%s' is not hexadecimal", tid)
+	}
+	return id, nil
+}
+
+// Matches reports whether the entry with the given fields is selected
+func (f *ThreadFilter) Matches(fields Fields) bool {
+	if f.name != nil && fields.Thread != "" && f.name.MatchString(fields.Thread) {
+		return true
+	}
+	if len(f.tids) > 0 && fields.TID != "" {
+		if id, err := parseTID(fields.TID); err == nil && f.tids[id] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package mergedlog_test
+
+import (
+	"merge-logs/mergedlog"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("thread filters", func() {
+	p2p := mergedlog.Fields{Thread: "P2P message reader for server2", TID: "0x4f"}
+	main := mergedlog.Fields{Thread: "main", TID: "0x1"}
+
+	It("selects threads by name", func() {
+		filter, err := mergedlog.NewThreadFilter("^P2P message reader", nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(filter.Matches(p2p)).To(BeTrue())
+		Expect(filter.Matches(main)).To(BeFalse())
+	})
+
+	It("selects threads by ID", func() {
+		filter, err := mergedlog.NewThreadFilter("", []string{"4F"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(filter.Matches(p2p)).To(BeTrue())
+		Expect(filter.Matches(main)).To(BeFalse())
+	})
+
+	It("selects threads matching either the name or an ID", func() {
+		filter, err := mergedlog.NewThreadFilter("^main$", []string{"0x4f"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(filter.Matches(p2p)).To(BeTrue())
+		Expect(filter.Matches(main)).To(BeTrue())
+	})
+
+	It("does not select entries without a thread", func() {
+		filter, err := mergedlog.NewThreadFilter(".*", []string{"0x1"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(filter.Matches(mergedlog.Fields{})).To(BeFalse())
+	})
+
+	It("rejects invalid filters", func() {
+		_, err := mergedlog.NewThreadFilter("main(", nil)
+		Expect(err).Should(HaveOccurred())
+		_, err = mergedlog.NewThreadFilter("", []string{"0xfoo"})
+		Expect(err).Should(HaveOccurred())
+	})
+})
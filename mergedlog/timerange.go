@@ -0,0 +1,387 @@
+package mergedlog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rangeLayouts are the layouts accepted for absolute times. Fractional seconds are optional
+// when parsing so they need not be part of the layouts.
+var rangeLayouts = []string{
+	"2006/01/02 15:04:05 MST",
+	"2006/01/02 15:04:05 -0700",
+	"2006/01/02 15:04:05",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006/01/02",
+	"2006-01-02",
+}
+
+// clockLayouts are the layouts accepted for times without a date
+var clockLayouts = []string{
+	"15:04:05 MST",
+	"15:04:05",
+	"15:04 MST",
+	"15:04",
+}
+
+// relativeTimeRE matches times relative to the first or last log entry, such as "last-5m"
+var relativeTimeRE = regexp.MustCompile(`^(first|last)\s*(?:([+-])\s*(\S+))?$`)
+
+type timeSpecKind int
+
+const (
+	absoluteTime timeSpecKind = iota
+	clockTime
+	firstRelative
+	lastRelative
+)
+
+// TimeSpec is a point in time as given for the start or stop of a [TimeRange]. Times without a
+// date and times relative to the first or last entry can only be resolved once the extent of
+// the logs is known.
+type TimeSpec struct {
+	text string
+	kind timeSpecKind
+	// time is the absolute time, or the time of day for a clock time
+	time time.Time
+	// zone is the zone abbreviation given with a clock time, if any
+	zone   string
+	offset time.Duration
+}
+
+// ParseTimeSpec parses a point in time. Accepted are:
+//   - absolute times, as written in GemFire logs ("2018/01/25 19:09:36.949 UTC") or ISO-8601
+//     ("2018-01-25T19:09:36Z", "2018-01-25 19:09:36")
+//   - times of day ("19:09:36", "19:09 PST"), on the date of the first log entry
+//   - times relative to the first or last log entry ("first+10m", "last-5m")
+//
+// Times without a zone are in the given location.
+func ParseTimeSpec(text string, location *time.Location) (*TimeSpec, error) {
+	text = strings.TrimSpace(text)
+
+	if m := relativeTimeRE.FindStringSubmatch(text); m != nil {
+		spec := &TimeSpec{text: text, kind: firstRelative}
+		if m[1] == "last" {
+			spec.kind = lastRelative
+		}
+		if m[3] != "" {
+			offset, err := time.ParseDuration(m[3])
+			if err != nil {
+				return nil, fmt.Errorf("time '%s': %w", text, err)
+			}
+			if m[2] == "-" {
+				offset = -offset
+			}
+			spec.offset = offset
+		}
+		return spec, nil
+	}
+
+	for _, layout := range rangeLayouts {
+		if t, err := time.ParseInLocation(layout, text, location); err == nil {
+			if strings.Contains(layout, "MST") {
+				name, _ := t.Zone()
+				t = resolveZone(t, name)
+			}
+			return &TimeSpec{text: text, kind: absoluteTime, time: t}, nil
+		}
+	}
+
+	for _, layout := range clockLayouts {
+		if t, err := time.ParseInLocation(layout, text, location); err == nil {
+			spec := &TimeSpec{text: text, kind: clockTime, time: t}
+			if strings.Contains(layout, "MST") {
+				spec.zone, _ = t.Zone()
+			}
+			return spec, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unable to parse '%s' as a time; expected for example '2018/01/25 19:09:36.949 UTC', "+
+		"'2018-01-25T19:09:36Z', '19:09:36', 'first+10m' or 'last-5m'", text)
+}
+
+// NeedsExtent reports whether resolving the time requires the extent of the logs
+func (s *TimeSpec) NeedsExtent() bool {
+	return s.kind != absoluteTime
+}
+
+// Resolve returns the time, using the extent of the logs for relative times and times of day.
+// A time of day is on the date of the first entry, unless that would be before the first entry
+// and the following day is still within the logs.
+func (s *TimeSpec) Resolve(extent *LogExtent) (time.Time, error) {
+	if s.kind == absoluteTime {
+		return s.time, nil
+	}
+	if extent == nil || extent.First.IsZero() {
+		return time.Time{}, fmt.Errorf("time '%s': no log entries to resolve it against", s.text)
+	}
+
+	switch s.kind {
+	case firstRelative:
+		return extent.First.Add(s.offset), nil
+	case lastRelative:
+		return extent.Last.Add(s.offset), nil
+	}
+
+	location := s.time.Location()
+	if s.zone != "" {
+		if zone := zoneLocation(s.zone); zone != nil {
+			location = zone
+		}
+	}
+	first := extent.First.In(location)
+	onDay := func(days int) time.Time {
+		t := time.Date(first.Year(), first.Month(), first.Day()+days,
+			s.time.Hour(), s.time.Minute(), s.time.Second(), s.time.Nanosecond(), s.time.Location())
+		if s.zone != "" {
+			t = resolveZone(t, s.zone)
+		}
+		return t
+	}
+
+	t := onDay(0)
+	if t.Before(extent.First) {
+		if next := onDay(1); !next.After(extent.Last) {
+			return next, nil
+		}
+	}
+	return t, nil
+}
+
+// ParseRangeDuration parses the length of a time range, given either as whole seconds or as a
+// Go duration such as "90s" or "1h30m".
+func ParseRangeDuration(text string) (time.Duration, error) {
+	var duration time.Duration
+	if seconds, err := strconv.ParseInt(text, 10, 64); err == nil {
+		duration = time.Duration(seconds) * time.Second
+	} else if duration, err = time.ParseDuration(text); err != nil {
+		return 0, fmt.Errorf("duration '%s': %w", text, err)
+	}
+
+	if duration < 0 {
+		return 0, fmt.Errorf("duration '%s' is negative", text)
+	}
+	return duration, nil
+}
+
+// TimeRange limits the merge to the entries between a start and a stop time. Either may be
+// omitted, in which case it is derived from the other and the duration, or else left open.
+// Alternatively the range may be a window around an anchor time, or around the first entry
+// matching a regular expression.
+type TimeRange struct {
+	Start *TimeSpec
+	Stop  *TimeSpec
+	// Duration is zero if not given
+	Duration time.Duration
+	// Around is the anchor time, if any
+	Around *TimeSpec
+	// AroundMatch selects the entry whose timestamp is the anchor, if any
+	AroundMatch *regexp.Regexp
+	// Window is how far the range extends either side of the anchor
+	Window time.Duration
+}
+
+// ParseTimeRange parses the start, stop and duration of a range, any of which may be empty.
+// See [ParseTimeSpec] and [ParseRangeDuration].
+func ParseTimeRange(start, stop, duration string, location *time.Location) (*TimeRange, error) {
+	r := &TimeRange{}
+	var err error
+
+	if start != "" {
+		if r.Start, err = ParseTimeSpec(start, location); err != nil {
+			return nil, fmt.Errorf("start: %w", err)
+		}
+	}
+	if stop != "" {
+		if r.Stop, err = ParseTimeSpec(stop, location); err != nil {
+			return nil, fmt.Errorf("stop: %w", err)
+		}
+	}
+	if duration != "" {
+		if r.Duration, err = ParseRangeDuration(duration); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// SetAround centers the range on the anchor time, extending it by window either side. See
+// [ParseTimeSpec].
+func (r *TimeRange) SetAround(anchor string, window time.Duration, location *time.Location) error {
+	if err := r.checkAround(window); err != nil {
+		return err
+	}
+
+	spec, err := ParseTimeSpec(anchor, location)
+	if err != nil {
+		return fmt.Errorf("around: %w", err)
+	}
+	r.Around = spec
+	r.Window = window
+	return nil
+}
+
+// SetAroundMatch centers the range on the earliest entry matching the regular expression,
+// extending it by window either side.
+func (r *TimeRange) SetAroundMatch(pattern string, window time.Duration) error {
+	if err := r.checkAround(window); err != nil {
+		return err
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("around match: %w", err)
+	}
+	r.AroundMatch = re
+	r.Window = window
+	return nil
+}
+
+func (r *TimeRange) checkAround(window time.Duration) error {
+	if r.Start != nil || r.Stop != nil || r.Duration != 0 {
+		return fmt.Errorf("a window around a time cannot be combined with a start, stop or duration")
+	}
+	if r.Around != nil || r.AroundMatch != nil {
+		return fmt.Errorf("only one time or match to center the window on may be given")
+	}
+	if window < 0 {
+		return fmt.Errorf("window %s is negative", window)
+	}
+	return nil
+}
+
+// NeedsExtent reports whether resolving the range requires the extent of the logs. If so, the
+// extent must be found with the range's [TimeRange.AroundMatch] as its [LogExtent.Match].
+func (r *TimeRange) NeedsExtent() bool {
+	return (r.Start != nil && r.Start.NeedsExtent()) || (r.Stop != nil && r.Stop.NeedsExtent()) ||
+		(r.Around != nil && r.Around.NeedsExtent()) || r.AroundMatch != nil
+}
+
+// Resolve returns the range as Unix nanoseconds, suitable for [NewProcessor]. The extent may
+// be nil if it is not needed.
+func (r *TimeRange) Resolve(extent *LogExtent) (int64, int64, error) {
+	if r.Around != nil || r.AroundMatch != nil {
+		return r.resolveAround(extent)
+	}
+
+	rangeStart, rangeStop := int64(0), MAX_INT
+
+	if r.Start != nil {
+		t, err := r.Start.Resolve(extent)
+		if err != nil {
+			return 0, 0, fmt.Errorf("start: %w", err)
+		}
+		rangeStart = t.UnixNano()
+	}
+	if r.Stop != nil {
+		t, err := r.Stop.Resolve(extent)
+		if err != nil {
+			return 0, 0, fmt.Errorf("stop: %w", err)
+		}
+		rangeStop = t.UnixNano()
+	}
+
+	if r.Duration != 0 {
+		switch {
+		case r.Start != nil && r.Stop == nil:
+			rangeStop = rangeStart + int64(r.Duration)
+		case r.Start == nil && r.Stop != nil:
+			rangeStart = rangeStop - int64(r.Duration)
+		}
+	}
+
+	if rangeStop < rangeStart {
+		return 0, 0, fmt.Errorf("the range stops before it starts")
+	}
+
+	return rangeStart, rangeStop, nil
+}
+
+func (r *TimeRange) resolveAround(extent *LogExtent) (int64, int64, error) {
+	var anchor time.Time
+	if r.Around != nil {
+		var err error
+		if anchor, err = r.Around.Resolve(extent); err != nil {
+			return 0, 0, fmt.Errorf("around: %w", err)
+		}
+	} else {
+		if extent == nil || extent.Matched.IsZero() {
+			return 0, 0, fmt.Errorf("no entry matches '%s'", r.AroundMatch)
+		}
+		anchor = extent.Matched
+	}
+
+	return anchor.Add(-r.Window).UnixNano(), anchor.Add(r.Window).UnixNano(), nil
+}
+
+// LogExtent is the span of time covered by a set of logs, against which relative times are
+// resolved. Timestamps include the clock skew correction of each log, as they are compared with
+// the corrected timestamps of entries.
+type LogExtent struct {
+	First time.Time
+	Last  time.Time
+	// Match, if set, selects entries of which the earliest timestamp is recorded as Matched
+	Match   *regexp.Regexp
+	Matched time.Time
+	// Layout, if set, forces the layout of the timestamps instead of detecting it, as with
+	// [Processor.SetTimestampLayout]
+	Layout *TimestampLayout
+}
+
+// AddLog scans a log, extending the extent to include all of its entries
+func (e *LogExtent) AddLog(format LogFormat, reader io.Reader, maxBuffer int) error {
+	return e.AddAdjustedLog(format, 0, reader, maxBuffer)
+}
+
+// AddAdjustedLog scans a log whose timestamps are corrected by the offset, as given to
+// [Processor.SetOffset], extending the extent to include all of its entries
+func (e *LogExtent) AddAdjustedLog(format LogFormat, offset time.Duration, reader io.Reader, maxBuffer int) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(format.Split)
+	scanner.Buffer(make([]byte, bufio.MaxScanTokenSize), maxBuffer)
+
+	header := format.Header()
+	timestampIndex := header.SubexpIndex("timestamp")
+	timestamps := timestampParser{
+		layouts: format.Layouts(),
+		layout:  e.Layout,
+		forced:  e.Layout != nil,
+	}
+
+	for scanner.Scan() {
+		entry := scanner.Text()
+		matches := header.FindStringSubmatch(entry)
+		if matches == nil {
+			continue
+		}
+
+		t, _, _, _, err := timestamps.parse(matches[timestampIndex])
+		if err != nil {
+			continue
+		}
+		t = t.Add(offset)
+
+		if e.First.IsZero() || t.Before(e.First) {
+			e.First = t
+		}
+		if e.Last.IsZero() || t.After(e.Last) {
+			e.Last = t
+		}
+		if e.Match != nil && (e.Matched.IsZero() || t.Before(e.Matched)) && e.Match.MatchString(entry) {
+			e.Matched = t
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,234 @@
+package mergedlog_test
+
+import (
+	"merge-logs/mergedlog"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("time ranges", func() {
+	extent := &mergedlog.LogExtent{
+		First: time.Date(2018, 1, 25, 19, 0, 0, 0, time.UTC),
+		Last:  time.Date(2018, 1, 25, 20, 0, 0, 0, time.UTC),
+	}
+
+	resolve := func(text string) time.Time {
+		spec, err := mergedlog.ParseTimeSpec(text, time.UTC)
+		Expect(err).ShouldNot(HaveOccurred())
+		t, err := spec.Resolve(extent)
+		Expect(err).ShouldNot(HaveOccurred())
+		return t
+	}
+
+	DescribeTable("parsing absolute times",
+		func(text string, expected time.Time) {
+			Expect(resolve(text).UnixNano()).To(Equal(expected.UnixNano()))
+		},
+		Entry("gemfire", "2018/01/25 19:09:36.949 UTC", time.Date(2018, 1, 25, 19, 9, 36, 949000000, time.UTC)),
+		Entry("gemfire with zone abbreviation", "2018/01/25 11:09:36.949 PST", time.Date(2018, 1, 25, 19, 9, 36, 949000000, time.UTC)),
+		Entry("gemfire without milliseconds", "2018/01/25 19:09:36", time.Date(2018, 1, 25, 19, 9, 36, 0, time.UTC)),
+		Entry("ISO-8601", "2018-01-25T20:09:36.5+01:00", time.Date(2018, 1, 25, 19, 9, 36, 500000000, time.UTC)),
+		Entry("ISO-8601 without zone", "2018-01-25T19:09:36", time.Date(2018, 1, 25, 19, 9, 36, 0, time.UTC)),
+		Entry("ISO-8601 with a space", "2018-01-25 19:09:36,250", time.Date(2018, 1, 25, 19, 9, 36, 250000000, time.UTC)),
+		Entry("date only", "2018-01-25", time.Date(2018, 1, 25, 0, 0, 0, 0, time.UTC)),
+	)
+
+	It("parses times without a zone in the given location", func() {
+		tokyo, err := time.LoadLocation("Asia/Tokyo")
+		Expect(err).ShouldNot(HaveOccurred())
+		spec, err := mergedlog.ParseTimeSpec("2018/01/26 04:00:00", tokyo)
+		Expect(err).ShouldNot(HaveOccurred())
+		t, err := spec.Resolve(nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(t.UTC()).To(Equal(time.Date(2018, 1, 25, 19, 0, 0, 0, time.UTC)))
+	})
+
+	DescribeTable("resolving times against the logs",
+		func(text string, expected time.Time) {
+			Expect(resolve(text).UnixNano()).To(Equal(expected.UnixNano()))
+		},
+		Entry("time of day", "19:09:36", time.Date(2018, 1, 25, 19, 9, 36, 0, time.UTC)),
+		Entry("time of day with zone", "11:30 PST", time.Date(2018, 1, 25, 19, 30, 0, 0, time.UTC)),
+		Entry("first", "first", time.Date(2018, 1, 25, 19, 0, 0, 0, time.UTC)),
+		Entry("after first", "first+10m", time.Date(2018, 1, 25, 19, 10, 0, 0, time.UTC)),
+		Entry("before last", "last - 5m", time.Date(2018, 1, 25, 19, 55, 0, 0, time.UTC)),
+	)
+
+	It("resolves a time of day after midnight to the following day", func() {
+		extent := &mergedlog.LogExtent{
+			First: time.Date(2018, 1, 25, 23, 0, 0, 0, time.UTC),
+			Last:  time.Date(2018, 1, 26, 1, 0, 0, 0, time.UTC),
+		}
+		spec, err := mergedlog.ParseTimeSpec("00:30", time.UTC)
+		Expect(err).ShouldNot(HaveOccurred())
+		t, err := spec.Resolve(extent)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(t).To(Equal(time.Date(2018, 1, 26, 0, 30, 0, 0, time.UTC)))
+	})
+
+	It("requires log entries to resolve relative times", func() {
+		spec, err := mergedlog.ParseTimeSpec("last-5m", time.UTC)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(spec.NeedsExtent()).To(BeTrue())
+		_, err = spec.Resolve(&mergedlog.LogExtent{})
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("rejects unrecognized times", func() {
+		_, err := mergedlog.ParseTimeSpec("yesterday", time.UTC)
+		Expect(err).Should(HaveOccurred())
+		_, err = mergedlog.ParseTimeSpec("first+soon", time.UTC)
+		Expect(err).Should(HaveOccurred())
+	})
+
+	DescribeTable("parsing durations",
+		func(text string, expected time.Duration) {
+			d, err := mergedlog.ParseRangeDuration(text)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(d).To(Equal(expected))
+		},
+		Entry("whole seconds", "90", 90*time.Second),
+		Entry("Go duration", "90s", 90*time.Second),
+		Entry("compound Go duration", "1h30m", 90*time.Minute),
+	)
+
+	It("rejects negative durations", func() {
+		_, err := mergedlog.ParseRangeDuration("-5m")
+		Expect(err).Should(HaveOccurred())
+	})
+
+	Context("resolving a range", func() {
+		It("derives the stop from the start and duration", func() {
+			r, err := mergedlog.ParseTimeRange("first+10m", "", "5m", time.UTC)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(r.NeedsExtent()).To(BeTrue())
+			start, stop, err := r.Resolve(extent)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(start).To(Equal(time.Date(2018, 1, 25, 19, 10, 0, 0, time.UTC).UnixNano()))
+			Expect(stop).To(Equal(time.Date(2018, 1, 25, 19, 15, 0, 0, time.UTC).UnixNano()))
+		})
+
+		It("derives the start from the stop and duration", func() {
+			r, err := mergedlog.ParseTimeRange("", "2018/01/25 19:30:00 UTC", "60", time.UTC)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(r.NeedsExtent()).To(BeFalse())
+			start, stop, err := r.Resolve(nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(start).To(Equal(time.Date(2018, 1, 25, 19, 29, 0, 0, time.UTC).UnixNano()))
+			Expect(stop).To(Equal(time.Date(2018, 1, 25, 19, 30, 0, 0, time.UTC).UnixNano()))
+		})
+
+		It("is open ended when only the duration is given", func() {
+			r, err := mergedlog.ParseTimeRange("", "", "60", time.UTC)
+			Expect(err).ShouldNot(HaveOccurred())
+			start, stop, err := r.Resolve(nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(start).To(Equal(int64(0)))
+			Expect(stop).To(Equal(mergedlog.MAX_INT))
+		})
+
+		It("rejects a range which stops before it starts", func() {
+			r, err := mergedlog.ParseTimeRange("last", "first", "", time.UTC)
+			Expect(err).ShouldNot(HaveOccurred())
+			_, _, err = r.Resolve(extent)
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	It("finds the extent of logs", func() {
+		e := &mergedlog.LogExtent{}
+		Expect(e.AddLog(mergedlog.GemFireFormat, strings.NewReader(`[info 2018/01/25 19:05:00.000 UTC  a1
+[info 2018/01/25 19:07:00.000 UTC  a2`), 65536)).To(Succeed())
+		Expect(e.AddLog(mergedlog.GemFireFormat, strings.NewReader(`[info 2018/01/25 19:01:00.000 UTC  b1`), 65536)).To(Succeed())
+		Expect(e.First.UTC()).To(Equal(time.Date(2018, 1, 25, 19, 1, 0, 0, time.UTC)))
+		Expect(e.Last.UTC()).To(Equal(time.Date(2018, 1, 25, 19, 7, 0, 0, time.UTC)))
+	})
+
+	It("uses a forced timestamp layout when finding the extent", func() {
+		format, err := mergedlog.NewPatternFormat("slashes", `\d`, `^(?P<timestamp>\S+ \S+)`,
+			[]*mergedlog.TimestampLayout{mergedlog.NewTimestampLayout("mdy", "01/02/2006 15:04:05")})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		// Read as day/month, rather than detected as month/day, this log runs from 2 to 10 January
+		e := &mergedlog.LogExtent{Layout: mergedlog.NewTimestampLayout("dmy", "02/01/2006 15:04:05")}
+		Expect(e.AddLog(format, strings.NewReader(`10/01/2018 19:05:00 a1
+02/01/2018 19:07:00 a2`), 65536)).To(Succeed())
+		Expect(e.First.UTC()).To(Equal(time.Date(2018, 1, 2, 19, 7, 0, 0, time.Local).UTC()))
+		Expect(e.Last.UTC()).To(Equal(time.Date(2018, 1, 10, 19, 5, 0, 0, time.Local).UTC()))
+	})
+
+	Context("around an anchor", func() {
+		It("centers the range on the anchor time", func() {
+			r, err := mergedlog.ParseTimeRange("", "", "", time.UTC)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(r.SetAround("2018/01/25 19:09:36", 30*time.Second, time.UTC)).To(Succeed())
+			start, stop, err := r.Resolve(nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(start).To(Equal(time.Date(2018, 1, 25, 19, 9, 6, 0, time.UTC).UnixNano()))
+			Expect(stop).To(Equal(time.Date(2018, 1, 25, 19, 10, 6, 0, time.UTC).UnixNano()))
+		})
+
+		It("centers the range on the first matching entry", func() {
+			r, err := mergedlog.ParseTimeRange("", "", "", time.UTC)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(r.SetAroundMatch("ForcedDisconnect", time.Minute)).To(Succeed())
+			Expect(r.NeedsExtent()).To(BeTrue())
+
+			e := &mergedlog.LogExtent{Match: r.AroundMatch}
+			Expect(e.AddLog(mergedlog.GemFireFormat, strings.NewReader(`[info 2018/01/25 19:05:00.000 UTC  a1
+[fatal 2018/01/25 19:07:00.000 UTC  ForcedDisconnectException`), 65536)).To(Succeed())
+			Expect(e.AddLog(mergedlog.GemFireFormat, strings.NewReader(`[fatal 2018/01/25 19:06:00.000 UTC  b1
+org.apache.geode.ForcedDisconnectException: member kicked out`), 65536)).To(Succeed())
+
+			start, stop, err := r.Resolve(e)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(start).To(Equal(time.Date(2018, 1, 25, 19, 5, 0, 0, time.UTC).UnixNano()))
+			Expect(stop).To(Equal(time.Date(2018, 1, 25, 19, 7, 0, 0, time.UTC).UnixNano()))
+		})
+
+		It("fails when no entry matches", func() {
+			r, err := mergedlog.ParseTimeRange("", "", "", time.UTC)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(r.SetAroundMatch("ForcedDisconnect", time.Minute)).To(Succeed())
+			_, _, err = r.Resolve(&mergedlog.LogExtent{Match: r.AroundMatch})
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("rejects invalid regular expressions", func() {
+			r, err := mergedlog.ParseTimeRange("", "", "", time.UTC)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(r.SetAroundMatch("Forced(", time.Minute)).ToNot(Succeed())
+		})
+
+		It("cannot be combined with a start or stop", func() {
+			r, err := mergedlog.ParseTimeRange("first", "", "", time.UTC)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(r.SetAround("19:00", time.Minute, time.UTC)).ToNot(Succeed())
+		})
+
+		It("cannot be combined with a duration", func() {
+			r, err := mergedlog.ParseTimeRange("", "", "5m", time.UTC)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(r.SetAround("19:00", time.Minute, time.UTC)).ToNot(Succeed())
+			Expect(r.SetAroundMatch("ForcedDisconnect", time.Minute)).ToNot(Succeed())
+		})
+
+		It("applies the log's clock skew correction to the matching entry", func() {
+			r, err := mergedlog.ParseTimeRange("", "", "", time.UTC)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(r.SetAroundMatch("ForcedDisconnect", time.Minute)).To(Succeed())
+
+			e := &mergedlog.LogExtent{Match: r.AroundMatch}
+			Expect(e.AddAdjustedLog(mergedlog.GemFireFormat, 2*time.Second, strings.NewReader(
+				`[fatal 2018/01/25 19:07:00.000 UTC  ForcedDisconnectException`), 65536)).To(Succeed())
+
+			start, stop, err := r.Resolve(e)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(start).To(Equal(time.Date(2018, 1, 25, 19, 6, 2, 0, time.UTC).UnixNano()))
+			Expect(stop).To(Equal(time.Date(2018, 1, 25, 19, 8, 2, 0, time.UTC).UnixNano()))
+		})
+	})
+})
@@ -0,0 +1,219 @@
+package mergedlog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// TimestampLayout describes one recognized way of writing the timestamp at the start of a log
+// entry header (after the level).
+type TimestampLayout struct {
+	// Name identifies the layout, for example on the command line
+	Name string
+	// Layout is the Go time layout used to parse the timestamp
+	Layout string
+	// output, if set, is the Go time layout used to write timestamps, for layouts which would
+	// otherwise lose precision or the zone
+	output string
+	// pattern matches the timestamp at the start of the header text. If nil, the whole text is
+	// the timestamp.
+	pattern *regexp.Regexp
+}
+
+// NewTimestampLayout creates a layout for timestamps written with the given Go time layout.
+// The layout is expected to be given exactly the timestamp text, for example as captured by a
+// [PatternFormat] header.
+func NewTimestampLayout(name string, layout string) *TimestampLayout {
+	return &TimestampLayout{
+		Name:   name,
+		Layout: layout,
+	}
+}
+
+// TimestampLayouts are the recognized layouts, in the order they are tried when detecting the
+// layout of a file.
+var TimestampLayouts = []*TimestampLayout{
+	{
+		// 2018/01/25 19:09:36.949 UTC
+		Name:    "gemfire",
+		Layout:  STAMP_FORMAT,
+		pattern: regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}\.\d{3} \S+`),
+	},
+	{
+		// 2023-05-01T10:11:12.345+0000 as written by the Log4j2 based Geode layout
+		Name:    "log4j2",
+		Layout:  "2006-01-02T15:04:05.000-0700",
+		pattern: regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}[+-]\d{4}`),
+	},
+	{
+		// 2023-05-01T10:11:12.345Z or 2023-05-01T10:11:12+01:00
+		Name:    "iso8601",
+		Layout:  time.RFC3339Nano,
+		pattern: regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})`),
+	},
+	{
+		// 2023-05-01 10:11:12.345 UTC
+		Name:    "iso8601-zone",
+		Layout:  "2006-01-02 15:04:05.000 MST",
+		pattern: regexp.MustCompile(`^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d{3} [A-Za-z]\S*`),
+	},
+	{
+		// 2023-05-01 10:11:12.345 or 2023-05-01 10:11:12,345 in local time
+		Name:    "iso8601-local",
+		Layout:  "2006-01-02 15:04:05",
+		output:  "2006-01-02 15:04:05.000 MST",
+		pattern: regexp.MustCompile(`^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}([.,]\d+)?`),
+	},
+	{
+		// Oct 11 22:14:15 as used by RFC 3164 syslog messages, in local time and without a year
+		Name:    "rfc3164",
+		Layout:  time.Stamp,
+		output:  "Jan _2 15:04:05 MST",
+		pattern: regexp.MustCompile(`^[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}`),
+	},
+	{
+		// 10/Oct/2000:13:55:36 -0700 as used by Apache access logs
+		Name:    "apache",
+		Layout:  "02/Jan/2006:15:04:05 -0700",
+		pattern: regexp.MustCompile(`^\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}`),
+	},
+}
+
+// FindTimestampLayout returns the named layout or nil if there is no such layout
+func FindTimestampLayout(name string) *TimestampLayout {
+	for _, layout := range TimestampLayouts {
+		if layout.Name == name {
+			return layout
+		}
+	}
+	return nil
+}
+
+// TimestampLayoutNames returns the names of all the recognized layouts
+func TimestampLayoutNames() []string {
+	names := make([]string, len(TimestampLayouts))
+	for i, layout := range TimestampLayouts {
+		names[i] = layout.Name
+	}
+	return names
+}
+
+// Parse parses the timestamp at the start of text, ignoring anything following it. Timestamps
+// without a year are assumed to be from the last twelve months, and zone abbreviations are
+// interpreted using [ZoneAbbreviations].
+func (l *TimestampLayout) Parse(text string) (time.Time, error) {
+	t, _, _, err := l.parse(text)
+	return t, err
+}
+
+// parse parses the timestamp at the start of text, also returning where the timestamp starts and
+// ends in the text.
+func (l *TimestampLayout) parse(text string) (time.Time, int, int, error) {
+	start, end := 0, len(text)
+	if l.pattern != nil {
+		loc := l.pattern.FindStringIndex(text)
+		if loc == nil {
+			return time.Time{}, 0, 0, fmt.Errorf("'%s' does not start with a %s timestamp", text, l.Name)
+		}
+		start, end = loc[0], loc[1]
+	} else {
+		end = len(strings.TrimRight(text, " \t"))
+		start = end - len(strings.TrimLeft(text[:end], " \t"))
+	}
+	stamp := text[start:end]
+	if stamp == "" {
+		return time.Time{}, 0, 0, fmt.Errorf("'%s' does not start with a %s timestamp", text, l.Name)
+	}
+
+	t, err := time.ParseInLocation(l.Layout, stamp, time.Local)
+	if err != nil {
+		return t, 0, 0, err
+	}
+
+	if strings.Contains(l.Layout, "MST") {
+		name, _ := t.Zone()
+		t = resolveZone(t, name)
+	}
+
+	if t.Year() == 0 {
+		now := time.Now()
+		t = t.AddDate(now.Year(), 0, 0)
+		if t.After(now.Add(24 * time.Hour)) {
+			t = t.AddDate(-1, 0, 0)
+		}
+	}
+
+	return t, start, end, nil
+}
+
+// Format formats the time in the manner of the layout, for example to rewrite a timestamp in
+// another time zone. Unlike the Go layout used to parse timestamps, the time is always written
+// with its zone, and local ISO-8601 timestamps keep their milliseconds.
+func (l *TimestampLayout) Format(t time.Time) string {
+	if l.output != "" {
+		return t.Format(l.output)
+	}
+	if !hasZone(l.Layout) {
+		return t.Format(l.Layout + " MST")
+	}
+	return t.Format(l.Layout)
+}
+
+// hasZone reports whether the Go time layout includes the zone
+func hasZone(layout string) bool {
+	for _, zone := range []string{"MST", "Z07", "-07"} {
+		if strings.Contains(layout, zone) {
+			return true
+		}
+	}
+	return false
+}
+
+// layoutDetectionEntries is the number of entries at the start of a file used to detect which
+// layout its timestamps use.
+const layoutDetectionEntries = 5
+
+// timestampParser parses the timestamps of a single file. Unless a layout is forced, the layout
+// is detected, from the candidate layouts, using the first few entries and then used for the rest
+// of the file.
+type timestampParser struct {
+	layouts  []*TimestampLayout
+	layout   *TimestampLayout
+	forced   bool
+	examined int
+}
+
+// parse parses the timestamp at the start of text, returning the layout used as well as where
+// the timestamp starts and ends in the text.
+func (p *timestampParser) parse(text string) (time.Time, *TimestampLayout, int, int, error) {
+	var err error
+	if p.layout != nil {
+		var t time.Time
+		var start, end int
+		t, start, end, err = p.layout.parse(text)
+		if err == nil || p.forced || p.examined >= layoutDetectionEntries {
+			p.examined++
+			return t, p.layout, start, end, err
+		}
+	}
+
+	p.examined++
+	for _, layout := range p.layouts {
+		if t, start, end, err := layout.parse(text); err == nil {
+			p.layout = layout
+			return t, layout, start, end, nil
+		}
+	}
+
+	if err == nil {
+		names := make([]string, len(p.layouts))
+		for i, layout := range p.layouts {
+			names[i] = layout.Name
+		}
+		err = fmt.Errorf("'%s' does not start with any of the timestamp layouts: %s",
+			text, strings.Join(names, ", "))
+	}
+	return time.Time{}, nil, 0, 0, err
+}
@@ -0,0 +1,107 @@
+package mergedlog_test
+
+import (
+	"bufio"
+	"merge-logs/mergedlog"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("timestamp layouts", func() {
+	expected := time.Date(2023, 5, 1, 10, 11, 12, 345000000, time.UTC)
+
+	DescribeTable("parsing recognized layouts",
+		func(name string, text string) {
+			layout := mergedlog.FindTimestampLayout(name)
+			Expect(layout).ToNot(BeNil())
+
+			t, err := layout.Parse(text)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(t.UnixNano()).To(Equal(expected.UnixNano()))
+		},
+		Entry("gemfire", "gemfire", "2023/05/01 10:11:12.345 UTC server1 <main> tid=0x1]"),
+		Entry("log4j2", "log4j2", "2023-05-01T10:11:12.345+0000 server1 <main> tid=0x1]"),
+		Entry("log4j2 with offset", "log4j2", "2023-05-01T12:11:12.345+0200 server1"),
+		Entry("iso8601", "iso8601", "2023-05-01T10:11:12.345Z server1"),
+		Entry("iso8601 with offset", "iso8601", "2023-05-01T11:11:12.345+01:00 server1"),
+		Entry("iso8601 with zone", "iso8601-zone", "2023-05-01 10:11:12.345 UTC server1"),
+	)
+
+	It("parses local timestamps in the local time zone", func() {
+		t, err := mergedlog.FindTimestampLayout("iso8601-local").Parse("2023-05-01 10:11:12,345 server1")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(t).To(Equal(time.Date(2023, 5, 1, 10, 11, 12, 345000000, time.Local)))
+	})
+
+	It("rejects text not starting with the layout", func() {
+		_, err := mergedlog.FindTimestampLayout("gemfire").Parse("2023-05-01T10:11:12.345+0000 server1")
+		Expect(err).Should(HaveOccurred())
+	})
+
+	DescribeTable("formatting times with their zone",
+		func(name string, expected string) {
+			t := time.Date(2023, 5, 1, 10, 11, 12, 345000000, time.UTC)
+			Expect(mergedlog.FindTimestampLayout(name).Format(t)).To(Equal(expected))
+		},
+		Entry("gemfire", "gemfire", "2023/05/01 10:11:12.345 UTC"),
+		Entry("iso8601-local", "iso8601-local", "2023-05-01 10:11:12.345 UTC"),
+		Entry("rfc3164", "rfc3164", "May  1 10:11:12 UTC"),
+		Entry("apache", "apache", "01/May/2023:10:11:12 +0000"),
+	)
+
+	It("appends the zone when formatting with a layout which has none", func() {
+		layout := mergedlog.NewTimestampLayout("custom", "02.01.2006 15:04:05")
+		Expect(layout.Format(time.Date(2023, 5, 1, 10, 11, 12, 0, time.UTC))).To(Equal("01.05.2023 10:11:12 UTC"))
+	})
+
+	It("returns nil for an unknown layout", func() {
+		Expect(mergedlog.FindTimestampLayout("nope")).To(BeNil())
+	})
+
+	Context("when merging files with different layouts", func() {
+		It("detects the layout of each file", func() {
+			processor := mergedlog.NewProcessor(0, mergedlog.MAX_INT, nilRegex, nilRegex, 0)
+			processor.SetPalette(noopPalette)
+			result := &strings.Builder{}
+			processor.SetWriter(result)
+
+			file1 := `[info 2023/05/01 10:11:12.345 UTC server1 <main> tid=0x1] line1
+
+[info 2023/05/01 10:11:12.347 UTC server1 <main> tid=0x1] line3`
+			file2 := `[info 2023-05-01T10:11:12.346+0000 server2 <main> tid=0x1] line2`
+
+			processor.AddLog("", false, strings.NewReader(file1), bufio.MaxScanTokenSize)
+			processor.AddLog("", false, strings.NewReader(file2), bufio.MaxScanTokenSize)
+			processor.SetFormat(0)
+			processor.Crank()
+
+			Expect(strings.Split(strings.TrimSpace(result.String()), "\n")).To(Equal([]string{
+				"[] [info 2023/05/01 10:11:12.345 UTC server1 <main> tid=0x1] line1",
+				"[] ",
+				"[] [info 2023-05-01T10:11:12.346+0000 server2 <main> tid=0x1] line2",
+				"[] [info 2023/05/01 10:11:12.347 UTC server1 <main> tid=0x1] line3",
+			}))
+		})
+
+		It("uses a forced layout", func() {
+			processor := mergedlog.NewProcessor(0, mergedlog.MAX_INT, nilRegex, nilRegex, 0)
+			processor.SetPalette(noopPalette)
+			processor.SetTimestampLayout(mergedlog.FindTimestampLayout("log4j2"))
+			result := &strings.Builder{}
+			processor.SetWriter(result)
+
+			file1 := `[info 2023/05/01 10:11:12.345 UTC server1 <main> tid=0x1] skipped
+[info 2023-05-01T10:11:12.346+0000 server2 <main> tid=0x1] kept`
+
+			processor.AddLog("", false, strings.NewReader(file1), bufio.MaxScanTokenSize)
+			processor.SetFormat(0)
+			processor.Crank()
+
+			Expect(strings.TrimSpace(result.String())).To(Equal(
+				"[] [info 2023-05-01T10:11:12.346+0000 server2 <main> tid=0x1] kept"))
+		})
+	})
+})
@@ -1,15 +1,21 @@
 package mergedlog
 
 import (
+	"fmt"
 	"github.com/mgutz/ansi"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 )
 
+// STDIN_NAME is the filename used to read a log from standard input, optionally tagged as "tag:-"
+const STDIN_NAME = "-"
+
 // ProcessFilename processes a [tag:]file input into its constituent parts. In addition, a filename
 // will be parsed to try and determine if it is a rolled filename. Specifically if it ends in a
-// format like "-01-23.log". The returned values will be: original name, shorter name, tag
+// format like "-01-23.log". The returned values will be: original name, shorter name, tag.
+// Standard input, given as [STDIN_NAME], has the shorter name "stdin".
 func ProcessFilename(name string, useFullName bool) (string, string, *string) {
 	var fullName string
 	var shorterName string
@@ -24,19 +30,58 @@ func ProcessFilename(name string, useFullName bool) (string, string, *string) {
 		fullName = parts[0]
 	}
 
-	var r = regexp.MustCompile("(.*)-\\d+-\\d+.log")
-	var m = r.FindStringSubmatch(fullName)
+	if fullName == STDIN_NAME {
+		shorterName = "stdin"
+	} else {
+		shorterName = ShortenFilename(fullName, useFullName)
+	}
+
+	return fullName, shorterName, tag
+}
+
+var rolledLogRE = regexp.MustCompile("(.*)-\\d+-\\d+.log")
+
+// ShortenFilename strips any log rolling suffix (for example "-01-23.log") from a filename and,
+// unless useFullName is set, any leading directories.
+func ShortenFilename(name string, useFullName bool) string {
+	var shorterName string
+
+	var m = rolledLogRE.FindStringSubmatch(name)
 	if m != nil {
 		shorterName = m[1] + ".log"
 	} else {
-		shorterName = fullName
+		shorterName = name
 	}
 
 	if !useFullName {
 		shorterName = filepath.Base(shorterName)
 	}
 
-	return fullName, shorterName, tag
+	return shorterName
+}
+
+// ParseOffset parses a "tag=offset" clock skew correction, where offset is a signed Go duration
+// such as "+250ms" or "-1.5s".
+func ParseOffset(spec string) (string, time.Duration, error) {
+	tag, offsetStr, found := strings.Cut(spec, "=")
+	if !found || tag == "" {
+		return "", 0, fmt.Errorf("offset '%s' is not of the form tag=offset", spec)
+	}
+
+	offset, err := time.ParseDuration(offsetStr)
+	if err != nil {
+		return "", 0, fmt.Errorf("offset '%s': %w", spec, err)
+	}
+
+	return tag, offset, nil
+}
+
+// formatOffset formats a clock offset with an explicit sign, as accepted by [ParseOffset]
+func formatOffset(offset time.Duration) string {
+	if offset < 0 {
+		return offset.String()
+	}
+	return "+" + offset.String()
 }
 
 func MakeGrepRegex(regex string) *regexp.Regexp {
@@ -57,5 +102,6 @@ var MakePaletteEntry = func(s string) ColorFn {
 		Normal:    MakeColorFn(s),
 		Grep:      MakeColorFn(s + "+i"),
 		Highlight: MakeColorFn(s + "+i"),
+		Color:     s,
 	}
 }
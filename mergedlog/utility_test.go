@@ -4,6 +4,7 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"merge-logs/mergedlog"
+	"time"
 )
 
 var _ = Describe("MergeGfLogs", func() {
@@ -56,5 +57,39 @@ var _ = Describe("MergeGfLogs", func() {
 			Expect(shorter).To(Equal("server.log"))
 			Expect(tag).To(BeNil())
 		})
+		It("returns stdin", func() {
+			name, shorter, tag := mergedlog.ProcessFilename("-", true)
+			Expect(name).To(Equal(mergedlog.STDIN_NAME))
+			Expect(shorter).To(Equal("stdin"))
+			Expect(tag).To(BeNil())
+		})
+		It("returns stdin with tag", func() {
+			name, shorter, tag := mergedlog.ProcessFilename("tag:-", false)
+			Expect(name).To(Equal(mergedlog.STDIN_NAME))
+			Expect(shorter).To(Equal("stdin"))
+			Expect(*tag).To(Equal("tag"))
+		})
+	})
+
+	Context("parsing offsets", func() {
+		It("returns the tag and offset", func() {
+			tag, offset, err := mergedlog.ParseOffset("server1=+250ms")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(tag).To(Equal("server1"))
+			Expect(offset).To(Equal(250 * time.Millisecond))
+		})
+		It("returns negative offsets", func() {
+			_, offset, err := mergedlog.ParseOffset("server1=-1.5s")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(offset).To(Equal(-1500 * time.Millisecond))
+		})
+		It("rejects a missing tag", func() {
+			_, _, err := mergedlog.ParseOffset("+250ms")
+			Expect(err).Should(HaveOccurred())
+		})
+		It("rejects an invalid duration", func() {
+			_, _, err := mergedlog.ParseOffset("server1=250")
+			Expect(err).Should(HaveOccurred())
+		})
 	})
 })
@@ -0,0 +1,115 @@
+package mergedlog
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ZoneAbbreviations maps the time zone abbreviations found in log timestamps to IANA time zones.
+// Go can only interpret an abbreviation if it belongs to the local time zone, and otherwise
+// treats it as UTC, so without this table a "PST" log read on a UTC host would be 8 hours out.
+// Several abbreviations are ambiguous; the defaults below can be changed with
+// [SetZoneAbbreviation].
+var ZoneAbbreviations = map[string]string{
+	"PST":  "America/Los_Angeles",
+	"PDT":  "America/Los_Angeles",
+	"MST":  "America/Denver",
+	"MDT":  "America/Denver",
+	"CST":  "America/Chicago", // also China Standard Time (Asia/Shanghai) and Cuba
+	"CDT":  "America/Chicago",
+	"EST":  "America/New_York",
+	"EDT":  "America/New_York",
+	"AKST": "America/Anchorage",
+	"AKDT": "America/Anchorage",
+	"HST":  "Pacific/Honolulu",
+	"BST":  "Europe/London", // also Bangladesh Standard Time (Asia/Dhaka)
+	"WET":  "Europe/Lisbon",
+	"WEST": "Europe/Lisbon",
+	"CET":  "Europe/Paris",
+	"CEST": "Europe/Paris",
+	"EET":  "Europe/Helsinki",
+	"EEST": "Europe/Helsinki",
+	"MSK":  "Europe/Moscow",
+	"IST":  "Asia/Kolkata", // also Irish (Europe/Dublin) and Israel (Asia/Jerusalem) Standard Time
+	"SGT":  "Asia/Singapore",
+	"HKT":  "Asia/Hong_Kong",
+	"JST":  "Asia/Tokyo",
+	"KST":  "Asia/Seoul",
+	"AEST": "Australia/Sydney",
+	"AEDT": "Australia/Sydney",
+	"NZST": "Pacific/Auckland",
+	"NZDT": "Pacific/Auckland",
+}
+
+var (
+	zoneLocations     = make(map[string]*time.Location)
+	zoneLocationsLock sync.Mutex
+)
+
+// SetZoneAbbreviation changes the time zone an abbreviation refers to, for example to have "IST"
+// mean Irish Standard Time with SetZoneAbbreviation("IST", "Europe/Dublin").
+func SetZoneAbbreviation(abbreviation string, name string) error {
+	if _, err := time.LoadLocation(name); err != nil {
+		return fmt.Errorf("zone %s: %w", abbreviation, err)
+	}
+	zoneLocationsLock.Lock()
+	defer zoneLocationsLock.Unlock()
+	ZoneAbbreviations[abbreviation] = name
+	delete(zoneLocations, abbreviation)
+	return nil
+}
+
+// ParseZoneMapping parses an "ABBREVIATION=Area/Location" mapping as given on the command line
+func ParseZoneMapping(spec string) (string, string, error) {
+	abbreviation, name, found := strings.Cut(spec, "=")
+	if !found || abbreviation == "" || name == "" {
+		return "", "", fmt.Errorf("zone mapping '%s' is not of the form ABBREVIATION=Area/Location", spec)
+	}
+	return abbreviation, name, nil
+}
+
+// zoneLocation returns the location for an abbreviation, or nil if it is not mapped
+func zoneLocation(abbreviation string) *time.Location {
+	zoneLocationsLock.Lock()
+	defer zoneLocationsLock.Unlock()
+
+	if loc, ok := zoneLocations[abbreviation]; ok {
+		return loc
+	}
+
+	name, ok := ZoneAbbreviations[abbreviation]
+	if !ok {
+		return nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		loc = nil
+	}
+	zoneLocations[abbreviation] = loc
+	return loc
+}
+
+// resolveZone reinterprets a time parsed from a stamp containing the zone abbreviation in the
+// abbreviation's mapped time zone. The wall clock time is kept.
+func resolveZone(t time.Time, abbreviation string) time.Time {
+	loc := zoneLocation(abbreviation)
+	if loc == nil {
+		return t
+	}
+
+	resolved := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+	// Around a daylight saving transition the same wall clock time occurs twice; the
+	// abbreviation tells us which one is meant.
+	if name, _ := resolved.Zone(); name != abbreviation {
+		for _, shift := range []time.Duration{time.Hour, -time.Hour} {
+			alternative := resolved.Add(shift)
+			if name, _ := alternative.Zone(); name == abbreviation && alternative.Hour() == t.Hour() {
+				return alternative
+			}
+		}
+	}
+	return resolved
+}
@@ -0,0 +1,51 @@
+package mergedlog_test
+
+import (
+	"merge-logs/mergedlog"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("time zone abbreviations", func() {
+	gemfire := mergedlog.FindTimestampLayout("gemfire")
+
+	It("interprets abbreviations independently of the local time zone", func() {
+		t, err := gemfire.Parse("2015/11/19 08:52:39.504 PST server1")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(t.UTC()).To(Equal(time.Date(2015, 11, 19, 16, 52, 39, 504000000, time.UTC)))
+	})
+
+	It("uses the abbreviation to pick between repeated wall clock times", func() {
+		daylight, err := gemfire.Parse("2015/11/01 01:30:00.000 PDT server1")
+		Expect(err).ShouldNot(HaveOccurred())
+		standard, err := gemfire.Parse("2015/11/01 01:30:00.000 PST server1")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(standard.Sub(daylight)).To(Equal(time.Hour))
+	})
+
+	It("allows an ambiguous abbreviation to be remapped", func() {
+		original := mergedlog.ZoneAbbreviations["IST"]
+		DeferCleanup(mergedlog.SetZoneAbbreviation, "IST", original)
+
+		Expect(mergedlog.SetZoneAbbreviation("IST", "Europe/Dublin")).To(Succeed())
+		t, err := gemfire.Parse("2023/07/01 10:00:00.000 IST server1")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(t.UTC()).To(Equal(time.Date(2023, 7, 1, 9, 0, 0, 0, time.UTC)))
+	})
+
+	It("rejects mappings to unknown zones", func() {
+		Expect(mergedlog.SetZoneAbbreviation("XYZ", "Nowhere/Special")).ToNot(Succeed())
+	})
+
+	It("parses zone mappings", func() {
+		abbreviation, name, err := mergedlog.ParseZoneMapping("IST=Asia/Jerusalem")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(abbreviation).To(Equal("IST"))
+		Expect(name).To(Equal("Asia/Jerusalem"))
+
+		_, _, err = mergedlog.ParseZoneMapping("IST")
+		Expect(err).Should(HaveOccurred())
+	})
+})